@@ -1,5 +1,38 @@
 package compress
 
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// errors
+var (
+	ErrorNoInput        = errors.New("no input paths given")
+	ErrorDuplicateEntry = errors.New("duplicate archive entry")
+	ErrorInvalidEntry   = errors.New("invalid archive entry name")
+	ErrorUnsafeLink     = errors.New("archive entry resolves outside of the output directory")
+	ErrorEntryNotFound  = errors.New("archive entry not found")
+	ErrorNotAFile       = errors.New("archive entry is not a regular file")
+)
+
+// Error describes a failure on a particular file or archive entry
+type Error struct {
+	Op   string // "compress" or "decompress"
+	Path string // file path or archive entry name
+	Err  error
+}
+
+// Error implements error interface
+func (e *Error) Error() string { return fmt.Sprintf("%s %s: %v", e.Op, e.Path, e.Err) }
+
+// Unwrap returns the underlying error
+func (e *Error) Unwrap() error { return e.Err }
+
 // Compressor умеет упаковать файлы в архив
 type Compressor interface {
 	Compress(fileOut string, pathIn ...string) error
@@ -10,7 +43,70 @@ type Decompressor interface {
 	Decompress(fileIn string, pathOut string) error
 }
 
+// CompressorDecompressor включает интерфейсы Compressor и Decompressor
 type CompressorDecompressor interface {
 	Compressor
 	Decompressor
 }
+
+// ContextCompressor умеет упаковать файлы в архив, прерывая работу при завершении ctx
+type ContextCompressor interface {
+	CompressContext(ctx context.Context, fileOut string, pathIn ...string) error
+}
+
+// ContextDecompressor умеет распаковать файлы из архива, прерывая работу при завершении ctx
+type ContextDecompressor interface {
+	DecompressContext(ctx context.Context, fileIn string, pathOut string) error
+}
+
+// Lister умеет вернуть список записей архива без их извлечения
+type Lister interface {
+	List(fileIn string) ([]Header, error)
+}
+
+// EntryExtractor умеет записать в w содержимое единственной записи архива с именем name.
+// Если такой записи нет, возвращается ErrorEntryNotFound
+type EntryExtractor interface {
+	ExtractEntry(fileIn string, name string, w io.Writer) error
+}
+
+// Header describes an archive entry
+type Header struct {
+	Name     string      // slash-separated entry name, directory names end with "/"
+	Size     int64       // uncompressed size, -1 if unknown
+	Mode     os.FileMode // permission and mode bits
+	ModTime  time.Time   // modification time
+	Linkname string      // symlink target if Mode has os.ModeSymlink set
+}
+
+// IsDir returns true if the entry is a directory
+func (h Header) IsDir() bool { return h.Mode.IsDir() || strings.HasSuffix(h.Name, "/") }
+
+// IsSymlink returns true if the entry is a symbolic link
+func (h Header) IsSymlink() bool { return h.Mode&os.ModeSymlink != 0 }
+
+// Source is a named data stream to be put into an archive
+type Source struct {
+	Header
+	Reader io.Reader // entry contents, it is not read for directories and symlinks
+}
+
+// StreamCompressor умеет упаковать потоки sources в архив, записываемый в w
+type StreamCompressor interface {
+	CompressStream(w io.Writer, sources ...Source) error
+}
+
+// WalkFunc is called for every archive entry. Reader r is valid only until WalkFunc returns
+type WalkFunc func(h Header, r io.Reader) error
+
+// StreamDecompressor умеет обойти записи архива размером size, читаемого из r,
+// вызывая fn для каждой из них. Ошибка, возвращённая fn, прерывает обход
+type StreamDecompressor interface {
+	DecompressStream(r io.ReaderAt, size int64, fn WalkFunc) error
+}
+
+// StreamCompressorDecompressor включает интерфейсы StreamCompressor и StreamDecompressor
+type StreamCompressorDecompressor interface {
+	StreamCompressor
+	StreamDecompressor
+}
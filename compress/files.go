@@ -0,0 +1,320 @@
+package compress
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// FileCompressor makes a StreamCompressor satisfy Compressor
+type FileCompressor struct {
+	StreamCompressor
+	// KeepDirs preserves directory structure relative to the parent of every path in pathIn.
+	// If false, files are stored by their base names just like `zip -j` does
+	KeepDirs bool
+	Progress ProgressFunc // reports compression progress, may be nil
+}
+
+// Compress packs files and directories from pathIn into an archive fileOut.
+// Directories are walked recursively. On error fileOut is removed
+func (c FileCompressor) Compress(fileOut string, pathIn ...string) error {
+	return c.CompressContext(context.Background(), fileOut, pathIn...)
+}
+
+// CompressContext is like Compress but stops when ctx is done, removing fileOut.
+// The returned error matches ctx.Err() with errors.Is
+func (c FileCompressor) CompressContext(ctx context.Context, fileOut string, pathIn ...string) (err error) {
+	sources, err := Sources(c.KeepDirs, pathIn...)
+	if err != nil {
+		return err
+	}
+	t := &tracker{ctx: ctx, fn: c.Progress}
+
+	f, err := os.Create(fileOut)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(fileOut)
+		}
+	}()
+	if err := c.CompressStream(ctxWriter{ctx: ctx, w: f}, t.sources(sources)...); err != nil {
+		return err
+	}
+	t.progress.Entries = len(sources)
+	t.report("")
+	return nil
+}
+
+// FileDecompressor makes a StreamDecompressor satisfy Decompressor
+type FileDecompressor struct {
+	StreamDecompressor
+	Limits   Limits       // decompression limits
+	Progress ProgressFunc // reports decompression progress, may be nil
+}
+
+// Decompress extracts contents of an archive fileIn into pathOut, creating it if needed.
+// Entries with names or symlinks resolving outside pathOut are rejected
+// with ErrorInvalidEntry or ErrorUnsafeLink. Limits violations are reported with
+// ErrorTooManyEntries, ErrorTooLarge or ErrorRatioExceeded
+func (d FileDecompressor) Decompress(fileIn string, pathOut string) error {
+	return d.DecompressContext(context.Background(), fileIn, pathOut)
+}
+
+// DecompressContext is like Decompress but stops when ctx is done, removing a partially written file.
+// Entries extracted before are left in place. The returned error matches ctx.Err() with errors.Is
+func (d FileDecompressor) DecompressContext(ctx context.Context, fileIn string, pathOut string) error {
+	if err := os.MkdirAll(pathOut, 0777); err != nil {
+		return err
+	}
+	t := &tracker{ctx: ctx, fn: d.Progress}
+	return d.walkFile(fileIn, d.Limits, t.walk(func(h Header, r io.Reader) error {
+		if err := extract(h, r, pathOut); err != nil {
+			return &Error{Op: "decompress", Path: h.Name, Err: err}
+		}
+		return nil
+	}))
+}
+
+// List returns headers of all entries of an archive fileIn
+func (d FileDecompressor) List(fileIn string) ([]Header, error) {
+	headers := []Header{}
+	err := d.walkFile(fileIn, Limits{}, func(h Header, r io.Reader) error {
+		headers = append(headers, h)
+		return nil
+	})
+	return headers, err
+}
+
+// ExtractEntry writes contents of a regular file entry with given name of an archive fileIn into w.
+// It returns ErrorEntryNotFound if there is no such entry and ErrorNotAFile if the entry is not a regular file
+func (d FileDecompressor) ExtractEntry(fileIn string, name string, w io.Writer) error {
+	errFound := errors.New("found")
+	err := d.walkFile(fileIn, d.Limits, func(h Header, r io.Reader) error {
+		if h.Name != name {
+			return nil
+		}
+		if h.IsDir() || h.IsSymlink() {
+			return ErrorNotAFile
+		}
+		if _, err := io.Copy(w, r); err != nil {
+			return &Error{Op: "decompress", Path: h.Name, Err: err}
+		}
+		return errFound
+	})
+	switch {
+	case errors.Is(err, errFound):
+		return nil
+	case err == nil:
+		return ErrorEntryNotFound
+	}
+	return err
+}
+
+// walkFile calls fn for every entry of an archive fileIn enforcing limits
+func (d FileDecompressor) walkFile(fileIn string, limits Limits, fn WalkFunc) error {
+	f, err := os.Open(fileIn)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	return d.DecompressStream(f, info.Size(), limits.Guard(info.Size(), fn))
+}
+
+// Sources walks files and directories from pathIn and returns them as archive sources.
+// Files are opened only when their contents is read and closed on EOF.
+// See FileCompressor.KeepDirs for keepDirs meaning
+func Sources(keepDirs bool, pathIn ...string) ([]Source, error) {
+	if len(pathIn) == 0 {
+		return nil, ErrorNoInput
+	}
+
+	sources, names := []Source{}, map[string]bool{}
+	for _, root := range pathIn {
+		base := filepath.Dir(filepath.Clean(root))
+		err := filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+			if err != nil {
+				return &Error{Op: "compress", Path: p, Err: err}
+			}
+			if info.Mode()&os.ModeSymlink != 0 {
+				if info, err = os.Stat(p); err != nil {
+					return &Error{Op: "compress", Path: p, Err: err}
+				}
+			}
+			if info.IsDir() && !keepDirs || !info.IsDir() && !info.Mode().IsRegular() {
+				return nil
+			}
+
+			name := info.Name()
+			if keepDirs {
+				rel, err := filepath.Rel(base, p)
+				if err != nil {
+					return &Error{Op: "compress", Path: p, Err: err}
+				}
+				if rel == "." {
+					return nil
+				}
+				name = filepath.ToSlash(rel)
+			}
+			if info.IsDir() {
+				name += "/"
+			}
+			if names[name] {
+				return &Error{Op: "compress", Path: p, Err: ErrorDuplicateEntry}
+			}
+			names[name] = true
+
+			source := Source{Header: Header{Name: name, Mode: info.Mode(), ModTime: info.ModTime()}}
+			if !info.IsDir() {
+				source.Size, source.Reader = info.Size(), &fileReader{path: p}
+			}
+			sources = append(sources, source)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return sources, nil
+}
+
+// fileReader opens a file on the first Read call and closes it on EOF or error
+type fileReader struct {
+	path string
+	f    *os.File
+	done bool
+}
+
+// Read implements io.Reader
+func (r *fileReader) Read(p []byte) (int, error) {
+	if r.done {
+		return 0, io.EOF
+	}
+	if r.f == nil {
+		f, err := os.Open(r.path)
+		if err != nil {
+			r.done = true
+			return 0, &Error{Op: "compress", Path: r.path, Err: err}
+		}
+		r.f = f
+	}
+	n, err := r.f.Read(p)
+	if err != nil {
+		r.done = true
+		r.f.Close()
+		if err != io.EOF {
+			err = &Error{Op: "compress", Path: r.path, Err: err}
+		}
+	}
+	return n, err
+}
+
+// EntryPath returns a path inside pathOut to extract an archive entry with given name to.
+// It returns ErrorInvalidEntry if the name is absolute or points outside pathOut
+func EntryPath(pathOut, name string) (string, error) {
+	name = path.Clean(strings.Replace(name, `\`, "/", -1))
+	if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") || filepath.VolumeName(name) != "" {
+		return "", ErrorInvalidEntry
+	}
+	return filepath.Join(pathOut, filepath.FromSlash(name)), nil
+}
+
+// extract writes an archive entry with header h and contents r into pathOut
+func extract(h Header, r io.Reader, pathOut string) error {
+	target, err := EntryPath(pathOut, h.Name)
+	if err != nil {
+		return err
+	}
+	if err := checkSymlinks(pathOut, target); err != nil {
+		return err
+	}
+
+	if h.IsDir() {
+		return os.MkdirAll(target, 0777)
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0777); err != nil {
+		return err
+	}
+	if h.IsSymlink() {
+		linkPath := filepath.Join(filepath.Dir(target), filepath.FromSlash(h.Linkname))
+		if filepath.IsAbs(h.Linkname) || path.IsAbs(h.Linkname) || !isInside(pathOut, linkPath) {
+			return ErrorUnsafeLink
+		}
+		return os.Symlink(h.Linkname, target)
+	}
+
+	perm := h.Mode.Perm()
+	if perm == 0 {
+		perm = 0666
+	}
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, r); err != nil {
+		out.Close()
+		os.Remove(target)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	if h.ModTime.IsZero() {
+		return nil
+	}
+	return os.Chtimes(target, h.ModTime, h.ModTime)
+}
+
+// checkSymlinks returns ErrorUnsafeLink if any of already existing components
+// of target path is a symlink resolving outside root
+func checkSymlinks(root, target string) error {
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return err
+	}
+	rel, err := filepath.Rel(root, target)
+	if err != nil {
+		return err
+	}
+
+	current := root
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		current = filepath.Join(current, part)
+		info, err := os.Lstat(current)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			continue
+		}
+		resolved, err := filepath.EvalSymlinks(current)
+		if err != nil {
+			return ErrorUnsafeLink
+		}
+		if !isInside(realRoot, resolved) {
+			return ErrorUnsafeLink
+		}
+	}
+	return nil
+}
+
+// isInside returns true if path p is root or is located inside it
+func isInside(root, p string) bool {
+	rel, err := filepath.Rel(root, p)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,90 @@
+package compress
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Sources func", func() {
+	var tempPath string
+
+	BeforeEach(func() {
+		var err error
+		tempPath, err = ioutil.TempDir("", "compress")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.MkdirAll(filepath.Join(tempPath, "root", "dir"), 0777)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(tempPath, "root", "a"), []byte("a"), 0660)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(tempPath, "root", "dir", "b"), []byte("bb"), 0660)).To(Succeed())
+	})
+	AfterEach(func() { Expect(os.RemoveAll(tempPath)).To(Succeed()) })
+
+	names := func(sources []Source) []string {
+		result := []string{}
+		for _, source := range sources {
+			result = append(result, source.Name)
+		}
+		return result
+	}
+
+	It("junks directories", func() {
+		sources, err := Sources(false, filepath.Join(tempPath, "root"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(names(sources)).To(Equal([]string{"a", "b"}))
+		Expect(sources[1].Size).To(BeEquivalentTo(2))
+		Expect(ioutil.ReadAll(sources[1].Reader)).To(Equal([]byte("bb")))
+	})
+
+	It("keeps directories", func() {
+		sources, err := Sources(true, filepath.Join(tempPath, "root"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(names(sources)).To(Equal([]string{"root/", "root/a", "root/dir/", "root/dir/b"}))
+		Expect(sources[0].IsDir()).To(BeTrue())
+		Expect(sources[0].Reader).To(BeNil())
+	})
+
+	It("fails on duplicates", func() {
+		_, err := Sources(false, filepath.Join(tempPath, "root", "a"), filepath.Join(tempPath, "root", "a"))
+		Expect(errors.Is(err, ErrorDuplicateEntry)).To(BeTrue())
+	})
+
+	It("fails without input", func() {
+		_, err := Sources(false)
+		Expect(err).To(MatchError(ErrorNoInput))
+	})
+})
+
+var _ = Describe("EntryPath func", func() {
+	It("works", func() {
+		testCases := []struct {
+			name     string
+			expected string
+			err      error
+		}{
+			{name: "a", expected: filepath.Join("out", "a")},
+			{name: "dir/a", expected: filepath.Join("out", "dir", "a")},
+			{name: "dir/../a", expected: filepath.Join("out", "a")},
+			{name: `dir\a`, expected: filepath.Join("out", "dir", "a")},
+			{name: "../a", err: ErrorInvalidEntry},
+			{name: "dir/../../a", err: ErrorInvalidEntry},
+			{name: "..", err: ErrorInvalidEntry},
+			{name: "/etc/passwd", err: ErrorInvalidEntry},
+		}
+
+		for i, tc := range testCases {
+			By(fmt.Sprintf("testing case %d: %s", i, tc.name))
+			p, err := EntryPath("out", tc.name)
+			if tc.err != nil {
+				Expect(err).To(MatchError(tc.err))
+				continue
+			}
+			Expect(err).NotTo(HaveOccurred())
+			Expect(p).To(Equal(tc.expected))
+		}
+	})
+})
@@ -0,0 +1,156 @@
+package gzip
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/mtfelian/utils/compress"
+)
+
+// errors
+var (
+	ErrorSingleFile = errors.New("gzip holds exactly one file")
+)
+
+// Params is a set of gzip compressor parameters
+type Params struct {
+	Level    int                   // compression level from 1 (best speed) to 9 (best compression), 0 means default
+	Limits   compress.Limits       // decompression limits, compress.DefaultLimits if zero
+	Progress compress.ProgressFunc // reports Compress and Decompress progress, may be nil
+}
+
+// Gzip is a compress.CompressorDecompressor and compress.StreamCompressorDecompressor
+// implementation built on compress/gzip. It packs exactly one file
+type Gzip struct{ params Params }
+
+var (
+	_ compress.CompressorDecompressor       = &Gzip{}
+	_ compress.ContextCompressor            = &Gzip{}
+	_ compress.ContextDecompressor          = &Gzip{}
+	_ compress.StreamCompressorDecompressor = &Gzip{}
+	_ compress.Lister                       = &Gzip{}
+	_ compress.EntryExtractor               = &Gzip{}
+)
+
+func init() {
+	compress.Register(compress.Format{
+		Name:       "gzip",
+		Extensions: []string{".gz"},
+		Match:      func(head []byte) bool { return compress.IsGzip(head) && !compress.IsTarGz(head) },
+		Archiver:   New(Params{Limits: compress.DefaultLimits}),
+	})
+}
+
+// New returns a new gzip compressor/decompressor with given params.
+// Zero Limits are replaced with compress.DefaultLimits
+func New(params Params) *Gzip {
+	params.Limits = params.Limits.OrDefault()
+	return &Gzip{params: params}
+}
+
+// Compress сжимает единственный файл из pathIn в файл с путём и именем fileOut.
+// Если при сжатии произошла ошибка, fileOut удаляется
+func (g *Gzip) Compress(fileOut string, pathIn ...string) error {
+	return g.fileCompressor().Compress(fileOut, pathIn...)
+}
+
+// Decompress распаковывает файл из архива с путём и именем fileIn в папку pathOut.
+// Если имя файла не сохранено в архиве, используется имя fileIn без расширения .gz
+func (g *Gzip) Decompress(fileIn string, pathOut string) error {
+	return g.fileDecompressor(fileIn).Decompress(fileIn, pathOut)
+}
+
+// CompressContext is like Compress but stops when ctx is done
+func (g *Gzip) CompressContext(ctx context.Context, fileOut string, pathIn ...string) error {
+	return g.fileCompressor().CompressContext(ctx, fileOut, pathIn...)
+}
+
+// DecompressContext is like Decompress but stops when ctx is done
+func (g *Gzip) DecompressContext(ctx context.Context, fileIn string, pathOut string) error {
+	return g.fileDecompressor(fileIn).DecompressContext(ctx, fileIn, pathOut)
+}
+
+// List returns a header of the file packed into archive fileIn
+func (g *Gzip) List(fileIn string) ([]compress.Header, error) {
+	return g.fileDecompressor(fileIn).List(fileIn)
+}
+
+// ExtractEntry writes contents of the file packed into archive fileIn into w if its name equals to name
+func (g *Gzip) ExtractEntry(fileIn string, name string, w io.Writer) error {
+	return g.fileDecompressor(fileIn).ExtractEntry(fileIn, name, w)
+}
+
+// fileCompressor returns compress.FileCompressor for g
+func (g *Gzip) fileCompressor() compress.FileCompressor {
+	return compress.FileCompressor{StreamCompressor: g, Progress: g.params.Progress}
+}
+
+// fileDecompressor returns compress.FileDecompressor for g, using the name of fileIn without
+// .gz extension as the file name if the archive has no name stored
+func (g *Gzip) fileDecompressor(fileIn string) compress.FileDecompressor {
+	name := strings.TrimSuffix(filepath.Base(fileIn), filepath.Ext(fileIn))
+	return compress.FileDecompressor{
+		StreamDecompressor: defaultName{g, name},
+		Limits:             g.params.Limits,
+		Progress:           g.params.Progress,
+	}
+}
+
+// CompressStream writes a single source into w. The source name is stored without directories
+func (g *Gzip) CompressStream(w io.Writer, sources ...compress.Source) error {
+	if len(sources) != 1 || sources[0].IsDir() {
+		return ErrorSingleFile
+	}
+	source := sources[0]
+
+	level := g.params.Level
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	gw, err := gzip.NewWriterLevel(w, level)
+	if err != nil {
+		return err
+	}
+	gw.Name, gw.ModTime = path.Base(source.Name), source.ModTime
+	if _, err := io.Copy(gw, source.Reader); err != nil {
+		return &compress.Error{Op: "compress", Path: source.Name, Err: err}
+	}
+	return gw.Close()
+}
+
+// DecompressStream calls fn for the file packed into gzip archive with given size read from r.
+// Header Name is empty if the archive has no file name stored
+func (g *Gzip) DecompressStream(r io.ReaderAt, size int64, fn compress.WalkFunc) error {
+	gr, err := gzip.NewReader(io.NewSectionReader(r, 0, size))
+	if err != nil {
+		return err
+	}
+	defer gr.Close()
+
+	name := gr.Name
+	if name != "" {
+		name = path.Base(name)
+	}
+	return fn(compress.Header{Name: name, Size: -1, ModTime: gr.ModTime}, gr)
+}
+
+// defaultName replaces an empty entry name with name
+type defaultName struct {
+	compress.StreamDecompressor
+	name string
+}
+
+// DecompressStream implements compress.StreamDecompressor
+func (d defaultName) DecompressStream(r io.ReaderAt, size int64, fn compress.WalkFunc) error {
+	return d.StreamDecompressor.DecompressStream(r, size, func(h compress.Header, r io.Reader) error {
+		if h.Name == "" {
+			h.Name = d.name
+		}
+		return fn(h, r)
+	})
+}
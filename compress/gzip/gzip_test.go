@@ -0,0 +1,80 @@
+package gzip
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mtfelian/utils/compress"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Testing with Ginkgo", func() {
+	var tempPath string
+
+	BeforeEach(func() {
+		var err error
+		tempPath, err = ioutil.TempDir("", "gzip")
+		Expect(err).NotTo(HaveOccurred())
+	})
+	AfterEach(func() { Expect(os.RemoveAll(tempPath)).To(Succeed()) })
+
+	It("stores the file name without directories", func() {
+		buf := &bytes.Buffer{}
+		Expect(New(Params{Level: 9}).CompressStream(buf,
+			compress.Source{Header: compress.Header{Name: "dir/a.txt"}, Reader: strings.NewReader("a")},
+		)).To(Succeed())
+
+		gr, err := gzip.NewReader(buf)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(gr.Name).To(Equal("a.txt"))
+		Expect(ioutil.ReadAll(gr)).To(Equal([]byte("a")))
+	})
+
+	It("uses archive name if no file name is stored", func() {
+		fileIn := filepath.Join(tempPath, "data.txt.gz")
+		f, err := os.Create(fileIn)
+		Expect(err).NotTo(HaveOccurred())
+		gw := gzip.NewWriter(f)
+		_, err = gw.Write([]byte("data"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(gw.Close()).To(Succeed())
+		Expect(f.Close()).To(Succeed())
+
+		Expect(New(Params{}).Decompress(fileIn, tempPath)).To(Succeed())
+		Expect(ioutil.ReadFile(filepath.Join(tempPath, "data.txt"))).To(Equal([]byte("data")))
+	})
+
+	It("applies default limits", func() {
+		fileIn := filepath.Join(tempPath, "bomb.gz")
+		f, err := os.Create(fileIn)
+		Expect(err).NotTo(HaveOccurred())
+		gw := gzip.NewWriter(f)
+		_, err = gw.Write(make([]byte, 10<<20))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(gw.Close()).To(Succeed())
+		Expect(f.Close()).To(Succeed())
+
+		err = New(Params{}).Decompress(fileIn, filepath.Join(tempPath, "out"))
+		Expect(errors.Is(err, compress.ErrorRatioExceeded)).To(BeTrue())
+		g := New(Params{Limits: compress.Limits{Unlimited: true}})
+		Expect(g.Decompress(fileIn, filepath.Join(tempPath, "out"))).To(Succeed())
+	})
+
+	It("refuses directories and several sources", func() {
+		g := New(Params{})
+		Expect(g.CompressStream(&bytes.Buffer{})).To(MatchError(ErrorSingleFile))
+		Expect(g.CompressStream(&bytes.Buffer{},
+			compress.Source{Header: compress.Header{Name: "dir/"}},
+		)).To(MatchError(ErrorSingleFile))
+		Expect(g.CompressStream(&bytes.Buffer{},
+			compress.Source{Header: compress.Header{Name: "a"}, Reader: strings.NewReader("a")},
+			compress.Source{Header: compress.Header{Name: "b"}, Reader: strings.NewReader("b")},
+		)).To(MatchError(ErrorSingleFile))
+	})
+})
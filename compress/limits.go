@@ -0,0 +1,91 @@
+package compress
+
+import (
+	"errors"
+	"io"
+)
+
+// errors
+var (
+	ErrorTooManyEntries = errors.New("archive has too many entries")
+	ErrorTooLarge       = errors.New("archive uncompressed size is too large")
+	ErrorRatioExceeded  = errors.New("archive compression ratio is too high")
+)
+
+// DefaultLimits are reasonable limits for archives received from untrusted sources
+var DefaultLimits = Limits{MaxEntries: 10000, MaxTotalSize: 1 << 30, MaxRatio: 100}
+
+// Limits restrict archive decompression. Zero value of a field means no limit on it.
+// Backends use DefaultLimits instead of zero Limits, so Unlimited should be set to turn limits off
+type Limits struct {
+	MaxEntries   int     // maximum number of entries
+	MaxTotalSize int64   // maximum total uncompressed size in bytes
+	MaxRatio     float64 // maximum ratio of total uncompressed size to the archive size
+	Unlimited    bool    // disables all limits, for archives from trusted sources only
+}
+
+// OrDefault returns DefaultLimits if l is zero and l otherwise
+func (l Limits) OrDefault() Limits {
+	if l == (Limits{}) {
+		return DefaultLimits
+	}
+	return l
+}
+
+// Guard returns a WalkFunc calling fn for every entry of an archive with size archiveSize
+// and failing with ErrorTooManyEntries, ErrorTooLarge or ErrorRatioExceeded on limits violation.
+// Sizes are checked on the actually read data, not only on sizes declared in entry headers.
+// If Unlimited is set, fn is returned as it is
+func (l Limits) Guard(archiveSize int64, fn WalkFunc) WalkFunc {
+	if l.Unlimited {
+		return fn
+	}
+	g := &guard{limits: l, archiveSize: archiveSize}
+	return func(h Header, r io.Reader) error {
+		g.entries++
+		if l.MaxEntries > 0 && g.entries > l.MaxEntries {
+			return ErrorTooManyEntries
+		}
+		if h.Size > 0 {
+			if err := g.check(g.total + h.Size); err != nil {
+				return err
+			}
+		}
+		return fn(h, &guardReader{guard: g, r: r})
+	}
+}
+
+// guard keeps Limits.Guard state
+type guard struct {
+	limits      Limits
+	archiveSize int64
+	entries     int
+	total       int64
+}
+
+// check returns an error if total uncompressed size violates limits
+func (g *guard) check(total int64) error {
+	if g.limits.MaxTotalSize > 0 && total > g.limits.MaxTotalSize {
+		return ErrorTooLarge
+	}
+	if g.limits.MaxRatio > 0 && g.archiveSize > 0 && float64(total)/float64(g.archiveSize) > g.limits.MaxRatio {
+		return ErrorRatioExceeded
+	}
+	return nil
+}
+
+// guardReader counts bytes read into guard total
+type guardReader struct {
+	guard *guard
+	r     io.Reader
+}
+
+// Read implements io.Reader
+func (r *guardReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.guard.total += int64(n)
+	if checkErr := r.guard.check(r.guard.total); checkErr != nil {
+		return n, checkErr
+	}
+	return n, err
+}
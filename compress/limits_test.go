@@ -0,0 +1,66 @@
+package compress
+
+import (
+	"io"
+	"io/ioutil"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Limits", func() {
+	readAll := func(h Header, r io.Reader) error {
+		_, err := ioutil.ReadAll(r)
+		return err
+	}
+
+	It("allows everything with zero limits", func() {
+		fn := Limits{}.Guard(1, readAll)
+		for i := 0; i < 100; i++ {
+			Expect(fn(Header{Name: "a", Size: 1000}, strings.NewReader(strings.Repeat("a", 1000)))).To(Succeed())
+		}
+	})
+
+	It("allows everything when Unlimited", func() {
+		fn := Limits{MaxEntries: 1, Unlimited: true}.Guard(1, readAll)
+		for i := 0; i < 100; i++ {
+			Expect(fn(Header{Name: "a", Size: 1000}, strings.NewReader(strings.Repeat("a", 1000)))).To(Succeed())
+		}
+	})
+
+	It("checks OrDefault", func() {
+		Expect(Limits{}.OrDefault()).To(Equal(DefaultLimits))
+		Expect(Limits{MaxEntries: 1}.OrDefault()).To(Equal(Limits{MaxEntries: 1}))
+		Expect(Limits{Unlimited: true}.OrDefault()).To(Equal(Limits{Unlimited: true}))
+	})
+
+	It("checks entries count", func() {
+		fn := Limits{MaxEntries: 2}.Guard(0, readAll)
+		Expect(fn(Header{Name: "a"}, strings.NewReader("a"))).To(Succeed())
+		Expect(fn(Header{Name: "b"}, strings.NewReader("b"))).To(Succeed())
+		Expect(fn(Header{Name: "c"}, strings.NewReader("c"))).To(MatchError(ErrorTooManyEntries))
+	})
+
+	It("checks declared total size", func() {
+		called := false
+		fn := Limits{MaxTotalSize: 10}.Guard(0, func(Header, io.Reader) error {
+			called = true
+			return nil
+		})
+		Expect(fn(Header{Name: "a", Size: 11}, strings.NewReader(""))).To(MatchError(ErrorTooLarge))
+		Expect(called).To(BeFalse())
+	})
+
+	It("checks actually read total size", func() {
+		fn := Limits{MaxTotalSize: 10}.Guard(0, readAll)
+		Expect(fn(Header{Name: "a", Size: 1}, strings.NewReader("123456"))).To(Succeed())
+		Expect(fn(Header{Name: "b", Size: -1}, strings.NewReader("123456"))).To(MatchError(ErrorTooLarge))
+	})
+
+	It("checks compression ratio", func() {
+		fn := Limits{MaxRatio: 10}.Guard(10, readAll)
+		Expect(fn(Header{Name: "a"}, strings.NewReader(strings.Repeat("a", 100)))).To(Succeed())
+		Expect(fn(Header{Name: "b"}, strings.NewReader("b"))).To(MatchError(ErrorRatioExceeded))
+	})
+})
@@ -0,0 +1,101 @@
+package compress
+
+import (
+	"context"
+	"io"
+)
+
+// Progress describes how much of an archive has been processed so far
+type Progress struct {
+	Entries int    // number of entries completely processed
+	Bytes   int64  // number of uncompressed bytes processed
+	Entry   string // name of the entry being processed
+}
+
+// ProgressFunc is called as data is compressed or decompressed.
+// It is called synchronously, so it should return quickly
+type ProgressFunc func(p Progress)
+
+// tracker checks ctx for cancellation and reports progress to fn
+type tracker struct {
+	ctx      context.Context
+	fn       ProgressFunc
+	progress Progress
+}
+
+// report calls tracker's ProgressFunc if any
+func (t *tracker) report(entry string) {
+	t.progress.Entry = entry
+	if t.fn != nil {
+		t.fn(t.progress)
+	}
+}
+
+// sources returns sources with readers counting read bytes and failing when ctx is done
+func (t *tracker) sources(sources []Source) []Source {
+	result := make([]Source, len(sources))
+	for i, source := range sources {
+		if source.Reader != nil {
+			source.Reader = &progressReader{tracker: t, r: source.Reader, entry: source.Name, index: i}
+		}
+		result[i] = source
+	}
+	return result
+}
+
+// walk returns a WalkFunc calling fn for every entry until ctx is done and counting processed entries
+func (t *tracker) walk(fn WalkFunc) WalkFunc {
+	return func(h Header, r io.Reader) error {
+		if err := t.ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(h, &progressReader{tracker: t, r: r, entry: h.Name, index: -1}); err != nil {
+			return err
+		}
+		t.progress.Entries++
+		t.report(h.Name)
+		return nil
+	}
+}
+
+// progressReader reads r counting bytes into tracker. If index is not negative, it is
+// the index of the entry in the list of all entries, used to count processed entries
+type progressReader struct {
+	tracker *tracker
+	r       io.Reader
+	entry   string
+	index   int
+}
+
+// Read implements io.Reader
+func (r *progressReader) Read(p []byte) (int, error) {
+	if err := r.tracker.ctx.Err(); err != nil {
+		return 0, err
+	}
+	n, err := r.r.Read(p)
+	r.tracker.progress.Bytes += int64(n)
+	if r.index >= 0 {
+		r.tracker.progress.Entries = r.index
+		if err == io.EOF {
+			r.tracker.progress.Entries++
+		}
+	}
+	if n > 0 || err == io.EOF {
+		r.tracker.report(r.entry)
+	}
+	return n, err
+}
+
+// ctxWriter writes into w until ctx is done
+type ctxWriter struct {
+	ctx context.Context
+	w   io.Writer
+}
+
+// Write implements io.Writer
+func (w ctxWriter) Write(p []byte) (int, error) {
+	if err := w.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return w.w.Write(p)
+}
@@ -0,0 +1,150 @@
+package compress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// errors
+var (
+	ErrorUnknownFormat = errors.New("unknown archive format")
+)
+
+// HeadSize is a number of bytes from the beginning of a file passed to Format.Match
+const HeadSize = 4096
+
+// Format is an archive format known to the registry
+type Format struct {
+	Name       string                 // format name, e.g. "zip"
+	Extensions []string               // file name extensions including the dot, e.g. ".tar.gz"
+	Match      func(head []byte) bool // returns true if the head of a file has the format's magic bytes
+	Archiver   CompressorDecompressor // implementation to pack and unpack the format
+}
+
+var (
+	formatsMu sync.RWMutex
+	formats   []Format
+)
+
+// Register adds format f to the registry, replacing a format with the same name.
+// Backend packages register themselves on import, so to make DecompressAuto
+// recognize a format it is enough to import it, e.g.
+//
+//	import _ "github.com/mtfelian/utils/compress/zip"
+func Register(f Format) {
+	formatsMu.Lock()
+	defer formatsMu.Unlock()
+	for i := range formats {
+		if formats[i].Name == f.Name {
+			formats[i] = f
+			return
+		}
+	}
+	formats = append(formats, f)
+}
+
+// Formats returns all registered formats
+func Formats() []Format {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+	return append([]Format{}, formats...)
+}
+
+// Lookup returns a registered format by name
+func Lookup(name string) (Format, bool) {
+	for _, f := range Formats() {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return Format{}, false
+}
+
+// Detect returns a registered format of the archive fileIn. The format is detected by magic bytes,
+// if no format matches them, the longest matching file name extension is used.
+// It returns ErrorUnknownFormat if nothing matches
+func Detect(fileIn string) (Format, error) {
+	f, err := os.Open(fileIn)
+	if err != nil {
+		return Format{}, err
+	}
+	defer f.Close()
+
+	head := make([]byte, HeadSize)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return Format{}, err
+	}
+	head = head[:n]
+
+	registered := Formats()
+	for _, format := range registered {
+		if format.Match != nil && format.Match(head) {
+			return format, nil
+		}
+	}
+
+	var (
+		found     Format
+		maxExtLen int
+	)
+	lowerName := strings.ToLower(fileIn)
+	for _, format := range registered {
+		for _, ext := range format.Extensions {
+			if len(ext) > maxExtLen && strings.HasSuffix(lowerName, strings.ToLower(ext)) {
+				found, maxExtLen = format, len(ext)
+			}
+		}
+	}
+	if maxExtLen == 0 {
+		return Format{}, ErrorUnknownFormat
+	}
+	return found, nil
+}
+
+// DecompressAuto detects a format of the archive fileIn and extracts its contents into pathOut
+func DecompressAuto(fileIn string, pathOut string) error {
+	format, err := Detect(fileIn)
+	if err != nil {
+		return err
+	}
+	return format.Archiver.Decompress(fileIn, pathOut)
+}
+
+// IsZip returns true if head starts with zip magic bytes
+func IsZip(head []byte) bool {
+	for _, magic := range []string{"PK\x03\x04", "PK\x05\x06", "PK\x07\x08"} {
+		if bytes.HasPrefix(head, []byte(magic)) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsTar returns true if head contains a POSIX tar header
+func IsTar(head []byte) bool {
+	const magicOffset = 257
+	return len(head) >= magicOffset+5 && string(head[magicOffset:magicOffset+5]) == "ustar"
+}
+
+// IsGzip returns true if head starts with gzip magic bytes
+func IsGzip(head []byte) bool { return bytes.HasPrefix(head, []byte{0x1f, 0x8b}) }
+
+// IsTarGz returns true if head is a beginning of a gzipped tar archive
+func IsTarGz(head []byte) bool {
+	if !IsGzip(head) {
+		return false
+	}
+	gr, err := gzip.NewReader(bytes.NewReader(head))
+	if err != nil {
+		return false
+	}
+	tarHead := make([]byte, 512)
+	n, _ := io.ReadFull(gr, tarHead)
+	return IsTar(tarHead[:n])
+}
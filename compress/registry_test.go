@@ -0,0 +1,88 @@
+package compress_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/mtfelian/utils/compress"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("registry", func() {
+	var tempPath, srcFile string
+
+	BeforeEach(func() {
+		var err error
+		tempPath, err = ioutil.TempDir("", "compress")
+		Expect(err).NotTo(HaveOccurred())
+		srcFile = filepath.Join(tempPath, "data.txt")
+		Expect(ioutil.WriteFile(srcFile, []byte("data"), 0640)).To(Succeed())
+	})
+	AfterEach(func() { Expect(os.RemoveAll(tempPath)).To(Succeed()) })
+
+	It("has all backends registered", func() {
+		for _, name := range []string{"zip", "tar", "tar.gz", "gzip"} {
+			By(fmt.Sprintf("testing case %s", name))
+			format, ok := compress.Lookup(name)
+			Expect(ok).To(BeTrue())
+			Expect(format.Archiver).NotTo(BeNil())
+		}
+		_, ok := compress.Lookup("rar")
+		Expect(ok).To(BeFalse())
+	})
+
+	It("detects formats by magic bytes regardless of extension", func() {
+		for _, name := range []string{"zip", "tar", "tar.gz", "gzip"} {
+			By(fmt.Sprintf("testing case %s", name))
+			format, _ := compress.Lookup(name)
+			fileIn := filepath.Join(tempPath, "upload.bin")
+			Expect(format.Archiver.Compress(fileIn, srcFile)).To(Succeed())
+
+			detected, err := compress.Detect(fileIn)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(detected.Name).To(Equal(name))
+
+			pathOut := filepath.Join(tempPath, "out-"+name)
+			Expect(compress.DecompressAuto(fileIn, pathOut)).To(Succeed())
+			Expect(filepath.Join(pathOut, "data.txt")).To(BeAnExistingFile())
+			Expect(os.Remove(fileIn)).To(Succeed())
+		}
+	})
+
+	It("falls back to the longest matching extension", func() {
+		testCases := map[string]string{
+			"a.zip":    "zip",
+			"a.TAR":    "tar",
+			"a.tar.gz": "tar.gz",
+			"a.tgz":    "tar.gz",
+			"a.gz":     "gzip",
+		}
+		for fileName, name := range testCases {
+			By(fmt.Sprintf("testing case %s", fileName))
+			fileIn := filepath.Join(tempPath, fileName)
+			Expect(ioutil.WriteFile(fileIn, []byte("no magic here"), 0640)).To(Succeed())
+			detected, err := compress.Detect(fileIn)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(detected.Name).To(Equal(name))
+		}
+	})
+
+	It("fails on unknown formats", func() {
+		_, err := compress.Detect(srcFile)
+		Expect(err).To(MatchError(compress.ErrorUnknownFormat))
+		Expect(compress.DecompressAuto(srcFile, tempPath)).To(MatchError(compress.ErrorUnknownFormat))
+	})
+
+	It("replaces a format with the same name", func() {
+		format, _ := compress.Lookup("zip")
+		defer compress.Register(format)
+
+		compress.Register(compress.Format{Name: "zip", Extensions: []string{".zip2"}})
+		replaced, _ := compress.Lookup("zip")
+		Expect(replaced.Extensions).To(Equal([]string{".zip2"}))
+		Expect(compress.Formats()).To(HaveLen(4))
+	})
+})
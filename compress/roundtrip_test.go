@@ -0,0 +1,250 @@
+package compress_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mtfelian/utils/compress"
+	"github.com/mtfelian/utils/compress/gzip"
+	"github.com/mtfelian/utils/compress/tar"
+	"github.com/mtfelian/utils/compress/targz"
+	"github.com/mtfelian/utils/compress/zip"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// backend is a compressor under test
+type backend interface {
+	compress.CompressorDecompressor
+	compress.StreamCompressorDecompressor
+}
+
+// archiveBackends are able to pack several files with directory structure
+var archiveBackends = map[string]struct{ keepDirs, junkDirs backend }{
+	"zip":    {zip.New(zip.Params{KeepDirs: true}), zip.New(zip.Params{})},
+	"tar":    {tar.New(tar.Params{KeepDirs: true}), tar.New(tar.Params{})},
+	"tar.gz": {targz.New(targz.Params{KeepDirs: true}), targz.New(targz.Params{})},
+}
+
+// singleFileBackends are able to pack just one file
+var singleFileBackends = map[string]backend{
+	// file3 test data compresses better than DefaultLimits allow
+	"gzip": gzip.New(gzip.Params{Limits: compress.Limits{Unlimited: true}}),
+}
+
+var _ = Describe("round trip", func() {
+	var tempPath, srcPath string
+	testData := map[string]string{
+		"file1":           "file1data",
+		"dir1/file2":      "file2data",
+		"dir1/dir2/file3": strings.Repeat("file3data", 1000),
+		"dir1/empty":      "",
+	}
+
+	BeforeEach(func() {
+		var err error
+		tempPath, err = ioutil.TempDir("", "compress")
+		Expect(err).NotTo(HaveOccurred())
+		srcPath = filepath.Join(tempPath, "src")
+		for name, data := range testData {
+			p := filepath.Join(srcPath, filepath.FromSlash(name))
+			Expect(os.MkdirAll(filepath.Dir(p), 0777)).To(Succeed())
+			Expect(ioutil.WriteFile(p, []byte(data), 0640)).To(Succeed())
+		}
+		Expect(os.MkdirAll(filepath.Join(srcPath, "emptyDir"), 0777)).To(Succeed())
+	})
+	AfterEach(func() { Expect(os.RemoveAll(tempPath)).To(Succeed()) })
+
+	for name, b := range archiveBackends {
+		name, b := name, b
+
+		Context(name, func() {
+			It("keeps directory structure", func() {
+				fileOut, pathOut := filepath.Join(tempPath, "out."+name), filepath.Join(tempPath, "out")
+				Expect(b.keepDirs.Compress(fileOut, srcPath)).To(Succeed())
+				Expect(b.keepDirs.Decompress(fileOut, pathOut)).To(Succeed())
+
+				for p, data := range testData {
+					By(fmt.Sprintf("testing case %s", p))
+					Expect(ioutil.ReadFile(filepath.Join(pathOut, "src", filepath.FromSlash(p)))).
+						To(Equal([]byte(data)))
+				}
+				Expect(filepath.Join(pathOut, "src", "emptyDir")).To(BeADirectory())
+				info, err := os.Stat(filepath.Join(pathOut, "src", "file1"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(info.Mode().Perm()).To(Equal(os.FileMode(0640)))
+			})
+
+			It("junks directories", func() {
+				fileOut, pathOut := filepath.Join(tempPath, "out."+name), filepath.Join(tempPath, "out")
+				Expect(b.junkDirs.Compress(fileOut, srcPath)).To(Succeed())
+				Expect(b.junkDirs.Decompress(fileOut, pathOut)).To(Succeed())
+
+				files, err := ioutil.ReadDir(pathOut)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(files).To(HaveLen(len(testData)))
+				for p, data := range testData {
+					By(fmt.Sprintf("testing case %s", p))
+					Expect(ioutil.ReadFile(filepath.Join(pathOut, filepath.Base(p)))).To(Equal([]byte(data)))
+				}
+			})
+
+			It("fails on duplicate entries and removes fileOut", func() {
+				fileOut := filepath.Join(tempPath, "out."+name)
+				p := filepath.Join(srcPath, "file1")
+				Expect(errors.Is(b.junkDirs.Compress(fileOut, p, p), compress.ErrorDuplicateEntry)).To(BeTrue())
+				Expect(fileOut).NotTo(BeAnExistingFile())
+			})
+
+			It("lists and extracts entries", func() {
+				fileOut := filepath.Join(tempPath, "out."+name)
+				Expect(b.keepDirs.Compress(fileOut, srcPath)).To(Succeed())
+
+				lister, ok := b.keepDirs.(compress.Lister)
+				Expect(ok).To(BeTrue())
+				headers, err := lister.List(fileOut)
+				Expect(err).NotTo(HaveOccurred())
+				sizes := map[string]int64{}
+				for _, h := range headers {
+					sizes[h.Name] = h.Size
+					Expect(h.ModTime.IsZero()).To(BeFalse())
+				}
+				Expect(sizes).To(HaveKeyWithValue("src/dir1/dir2/file3", int64(len(testData["dir1/dir2/file3"]))))
+				Expect(sizes).To(HaveKey("src/emptyDir/"))
+				Expect(sizes).To(HaveLen(len(testData) + 4))
+
+				extractor, ok := b.keepDirs.(compress.EntryExtractor)
+				Expect(ok).To(BeTrue())
+				buf := &bytes.Buffer{}
+				Expect(extractor.ExtractEntry(fileOut, "src/dir1/file2", buf)).To(Succeed())
+				Expect(buf.String()).To(Equal(testData["dir1/file2"]))
+				Expect(extractor.ExtractEntry(fileOut, "src/missing", buf)).To(MatchError(compress.ErrorEntryNotFound))
+				Expect(extractor.ExtractEntry(fileOut, "src/dir1/", buf)).To(MatchError(compress.ErrorNotAFile))
+			})
+
+			It("reports progress", func() {
+				fileOut, pathOut := filepath.Join(tempPath, "out."+name), filepath.Join(tempPath, "out")
+				var total int64
+				for _, data := range testData {
+					total += int64(len(data))
+				}
+
+				var reports []compress.Progress
+				onProgress := func(p compress.Progress) { reports = append(reports, p) }
+				c := compress.FileCompressor{StreamCompressor: b.keepDirs, KeepDirs: true, Progress: onProgress}
+				Expect(c.Compress(fileOut, srcPath)).To(Succeed())
+				Expect(reports).NotTo(BeEmpty())
+				Expect(reports[len(reports)-1]).To(Equal(compress.Progress{Entries: len(testData) + 4, Bytes: total}))
+
+				reports = nil
+				d := compress.FileDecompressor{StreamDecompressor: b.keepDirs, Progress: onProgress}
+				Expect(d.Decompress(fileOut, pathOut)).To(Succeed())
+				last := reports[len(reports)-1]
+				Expect(last.Entries).To(Equal(len(testData) + 4))
+				Expect(last.Bytes).To(Equal(total))
+				for i := 1; i < len(reports); i++ {
+					Expect(reports[i].Bytes).To(BeNumerically(">=", reports[i-1].Bytes))
+					Expect(reports[i].Entries).To(BeNumerically(">=", reports[i-1].Entries))
+				}
+			})
+
+			It("stops on context cancellation removing partial output", func() {
+				fileOut, pathOut := filepath.Join(tempPath, "out."+name), filepath.Join(tempPath, "out")
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				err := b.keepDirs.(compress.ContextCompressor).CompressContext(ctx, fileOut, srcPath)
+				Expect(errors.Is(err, context.Canceled)).To(BeTrue())
+				Expect(fileOut).NotTo(BeAnExistingFile())
+
+				large := "src/large"
+				data := bytes.Repeat([]byte("large"), 1<<18)
+				Expect(ioutil.WriteFile(filepath.Join(tempPath, large), data, 0640)).To(Succeed())
+				Expect(b.keepDirs.Compress(fileOut, srcPath)).To(Succeed())
+				ctx, cancel = context.WithCancel(context.Background())
+				defer cancel()
+				d := compress.FileDecompressor{StreamDecompressor: b.keepDirs, Progress: func(p compress.Progress) {
+					if p.Entry == large {
+						cancel()
+					}
+				}}
+				err = d.DecompressContext(ctx, fileOut, pathOut)
+				Expect(errors.Is(err, context.Canceled)).To(BeTrue())
+				Expect(filepath.Join(pathOut, filepath.FromSlash(large))).NotTo(BeAnExistingFile())
+			})
+
+			It("works with streams", func() {
+				buf := &bytes.Buffer{}
+				Expect(b.keepDirs.CompressStream(buf,
+					compress.Source{Header: compress.Header{Name: "dir", Mode: os.ModeDir | 0755}},
+					compress.Source{Header: compress.Header{Name: "dir/a", Mode: 0600}, Reader: strings.NewReader("a")},
+					compress.Source{Header: compress.Header{Name: "b", Size: 2}, Reader: strings.NewReader("bb")},
+				)).To(Succeed())
+
+				contents := map[string]string{}
+				Expect(b.keepDirs.DecompressStream(bytes.NewReader(buf.Bytes()), int64(buf.Len()),
+					func(h compress.Header, r io.Reader) error {
+						data, err := ioutil.ReadAll(r)
+						contents[h.Name] = string(data)
+						return err
+					})).To(Succeed())
+				Expect(contents).To(Equal(map[string]string{"dir/": "", "dir/a": "a", "b": "bb"}))
+			})
+		})
+	}
+
+	for name, b := range singleFileBackends {
+		name, b := name, b
+
+		Context(name, func() {
+			It("packs a single file", func() {
+				fileOut, pathOut := filepath.Join(tempPath, "out."+name), filepath.Join(tempPath, "out")
+				Expect(b.Compress(fileOut, filepath.Join(srcPath, "dir1", "dir2", "file3"))).To(Succeed())
+				Expect(b.Decompress(fileOut, pathOut)).To(Succeed())
+				Expect(ioutil.ReadFile(filepath.Join(pathOut, "file3"))).To(Equal([]byte(testData["dir1/dir2/file3"])))
+			})
+
+			It("lists and extracts the file", func() {
+				fileOut := filepath.Join(tempPath, "out."+name)
+				Expect(b.Compress(fileOut, filepath.Join(srcPath, "file1"))).To(Succeed())
+
+				headers, err := b.(compress.Lister).List(fileOut)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(headers).To(HaveLen(1))
+				Expect(headers[0].Name).To(Equal("file1"))
+
+				buf := &bytes.Buffer{}
+				Expect(b.(compress.EntryExtractor).ExtractEntry(fileOut, "file1", buf)).To(Succeed())
+				Expect(buf.String()).To(Equal(testData["file1"]))
+			})
+
+			It("refuses to pack several files", func() {
+				fileOut := filepath.Join(tempPath, "out."+name)
+				Expect(b.Compress(fileOut, srcPath)).To(HaveOccurred())
+				Expect(fileOut).NotTo(BeAnExistingFile())
+			})
+
+			It("works with streams", func() {
+				buf := &bytes.Buffer{}
+				Expect(b.CompressStream(buf,
+					compress.Source{Header: compress.Header{Name: "dir/a"}, Reader: strings.NewReader("a")},
+				)).To(Succeed())
+
+				contents := map[string]string{}
+				Expect(b.DecompressStream(bytes.NewReader(buf.Bytes()), int64(buf.Len()),
+					func(h compress.Header, r io.Reader) error {
+						data, err := ioutil.ReadAll(r)
+						contents[h.Name] = string(data)
+						return err
+					})).To(Succeed())
+				Expect(contents).To(Equal(map[string]string{"a": "a"}))
+			})
+		})
+	}
+})
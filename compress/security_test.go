@@ -0,0 +1,148 @@
+package compress_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mtfelian/utils/compress"
+	ctar "github.com/mtfelian/utils/compress/tar"
+	czip "github.com/mtfelian/utils/compress/zip"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("unsafe archives", func() {
+	var tempPath, pathOut string
+
+	BeforeEach(func() {
+		var err error
+		tempPath, err = ioutil.TempDir("", "compress")
+		Expect(err).NotTo(HaveOccurred())
+		pathOut = filepath.Join(tempPath, "out")
+	})
+	AfterEach(func() { Expect(os.RemoveAll(tempPath)).To(Succeed()) })
+
+	// tarEntry is an entry of a crafted tar archive
+	type tarEntry struct {
+		name, linkname, data string
+		typeflag             byte
+	}
+
+	writeTar := func(entries ...tarEntry) string {
+		buf := &bytes.Buffer{}
+		tw := tar.NewWriter(buf)
+		for _, e := range entries {
+			Expect(tw.WriteHeader(&tar.Header{
+				Name: e.name, Linkname: e.linkname, Typeflag: e.typeflag, Mode: 0644, Size: int64(len(e.data)),
+			})).To(Succeed())
+			_, err := tw.Write([]byte(e.data))
+			Expect(err).NotTo(HaveOccurred())
+		}
+		Expect(tw.Close()).To(Succeed())
+		fileIn := filepath.Join(tempPath, "in.tar")
+		Expect(ioutil.WriteFile(fileIn, buf.Bytes(), 0644)).To(Succeed())
+		return fileIn
+	}
+
+	It("rejects entries resolving outside pathOut", func() {
+		testCases := []struct {
+			entries  []tarEntry
+			expected error
+		}{
+			{
+				entries:  []tarEntry{{name: "../evil", data: "evil", typeflag: tar.TypeReg}},
+				expected: compress.ErrorInvalidEntry,
+			},
+			{
+				entries:  []tarEntry{{name: "/tmp/evil", data: "evil", typeflag: tar.TypeReg}},
+				expected: compress.ErrorInvalidEntry,
+			},
+			{
+				entries:  []tarEntry{{name: "link", linkname: "/etc", typeflag: tar.TypeSymlink}},
+				expected: compress.ErrorUnsafeLink,
+			},
+			{
+				entries:  []tarEntry{{name: "dir/link", linkname: "../../evil", typeflag: tar.TypeSymlink}},
+				expected: compress.ErrorUnsafeLink,
+			},
+		}
+
+		for i, tc := range testCases {
+			By(fmt.Sprintf("testing case %d", i))
+			err := ctar.New(ctar.Params{}).Decompress(writeTar(tc.entries...), pathOut)
+			Expect(errors.Is(err, tc.expected)).To(BeTrue(), "%v", err)
+			Expect(filepath.Join(tempPath, "evil")).NotTo(BeAnExistingFile())
+		}
+	})
+
+	It("refuses to write through symlinks leading outside pathOut", func() {
+		outside := filepath.Join(tempPath, "outside")
+		Expect(os.MkdirAll(outside, 0777)).To(Succeed())
+		Expect(os.MkdirAll(pathOut, 0777)).To(Succeed())
+		Expect(os.Symlink(outside, filepath.Join(pathOut, "link"))).To(Succeed())
+
+		fileIn := writeTar(tarEntry{name: "link/evil", data: "evil", typeflag: tar.TypeReg})
+		err := ctar.New(ctar.Params{}).Decompress(fileIn, pathOut)
+		Expect(errors.Is(err, compress.ErrorUnsafeLink)).To(BeTrue())
+		Expect(filepath.Join(outside, "evil")).NotTo(BeAnExistingFile())
+	})
+
+	It("extracts symlinks resolving inside pathOut", func() {
+		fileIn := writeTar(
+			tarEntry{name: "dir/a", data: "a", typeflag: tar.TypeReg},
+			tarEntry{name: "link", linkname: "dir/a", typeflag: tar.TypeSymlink},
+		)
+		Expect(ctar.New(ctar.Params{}).Decompress(fileIn, pathOut)).To(Succeed())
+		Expect(ioutil.ReadFile(filepath.Join(pathOut, "link"))).To(Equal([]byte("a")))
+	})
+
+	It("checks zip symlinks", func() {
+		buf := &bytes.Buffer{}
+		zw := zip.NewWriter(buf)
+		header := &zip.FileHeader{Name: "link"}
+		header.SetMode(os.ModeSymlink | 0777)
+		w, err := zw.CreateHeader(header)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = w.Write([]byte("../evil"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(zw.Close()).To(Succeed())
+		fileIn := filepath.Join(tempPath, "in.zip")
+		Expect(ioutil.WriteFile(fileIn, buf.Bytes(), 0644)).To(Succeed())
+
+		err = czip.New(czip.Params{}).Decompress(fileIn, pathOut)
+		Expect(errors.Is(err, compress.ErrorUnsafeLink)).To(BeTrue())
+	})
+
+	It("enforces limits", func() {
+		fileIn := writeTar(
+			tarEntry{name: "a", data: strings.Repeat("a", 100000), typeflag: tar.TypeReg},
+			tarEntry{name: "b", data: "b", typeflag: tar.TypeReg},
+		)
+		zipIn := filepath.Join(tempPath, "in.zip")
+		Expect(ctar.New(ctar.Params{}).Decompress(fileIn, pathOut)).To(Succeed())
+		Expect(czip.New(czip.Params{}).Compress(zipIn, filepath.Join(pathOut, "a"))).To(Succeed())
+
+		testCases := []struct {
+			decompressor compress.Decompressor
+			fileIn       string
+			expected     error
+		}{
+			{ctar.New(ctar.Params{Limits: compress.Limits{MaxEntries: 1}}), fileIn, compress.ErrorTooManyEntries},
+			{ctar.New(ctar.Params{Limits: compress.Limits{MaxTotalSize: 1000}}), fileIn, compress.ErrorTooLarge},
+			{czip.New(czip.Params{Limits: compress.Limits{MaxTotalSize: 1000}}), zipIn, compress.ErrorTooLarge},
+			{czip.New(czip.Params{Limits: compress.Limits{MaxRatio: 10}}), zipIn, compress.ErrorRatioExceeded},
+		}
+		for i, tc := range testCases {
+			By(fmt.Sprintf("testing case %d", i))
+			err := tc.decompressor.Decompress(tc.fileIn, filepath.Join(tempPath, fmt.Sprintf("limits%d", i)))
+			Expect(errors.Is(err, tc.expected)).To(BeTrue(), "%v", err)
+		}
+	})
+})
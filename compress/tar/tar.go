@@ -0,0 +1,177 @@
+package tar
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/mtfelian/utils/compress"
+)
+
+// Params is a set of tar compressor parameters
+type Params struct {
+	// KeepDirs preserves directory structure relative to the parent of every path in pathIn.
+	// If false, files are stored by their base names
+	KeepDirs bool
+	Limits   compress.Limits       // decompression limits, compress.DefaultLimits if zero
+	Progress compress.ProgressFunc // reports Compress and Decompress progress, may be nil
+}
+
+// Tar is a compress.CompressorDecompressor and compress.StreamCompressorDecompressor
+// implementation built on archive/tar
+type Tar struct{ params Params }
+
+var (
+	_ compress.CompressorDecompressor       = &Tar{}
+	_ compress.ContextCompressor            = &Tar{}
+	_ compress.ContextDecompressor          = &Tar{}
+	_ compress.StreamCompressorDecompressor = &Tar{}
+	_ compress.Lister                       = &Tar{}
+	_ compress.EntryExtractor               = &Tar{}
+)
+
+func init() {
+	compress.Register(compress.Format{
+		Name:       "tar",
+		Extensions: []string{".tar"},
+		Match:      compress.IsTar,
+		Archiver:   New(Params{KeepDirs: true, Limits: compress.DefaultLimits}),
+	})
+}
+
+// New returns a new tar compressor/decompressor with given params.
+// Zero Limits are replaced with compress.DefaultLimits
+func New(params Params) *Tar {
+	params.Limits = params.Limits.OrDefault()
+	return &Tar{params: params}
+}
+
+// Compress создаёт архив с путём и именем fileOut из файлов и папок, переданных в pathIn.
+// Папки обходятся рекурсивно. Если при создании архива произошла ошибка, fileOut удаляется
+func (t *Tar) Compress(fileOut string, pathIn ...string) error {
+	return t.fileCompressor().Compress(fileOut, pathIn...)
+}
+
+// Decompress извлекает из архива с путём и именем fileIn содержимое и помещает его в pathOut.
+// Папка pathOut создаётся, если её не существует
+func (t *Tar) Decompress(fileIn string, pathOut string) error {
+	return t.fileDecompressor().Decompress(fileIn, pathOut)
+}
+
+// CompressContext is like Compress but stops when ctx is done
+func (t *Tar) CompressContext(ctx context.Context, fileOut string, pathIn ...string) error {
+	return t.fileCompressor().CompressContext(ctx, fileOut, pathIn...)
+}
+
+// DecompressContext is like Decompress but stops when ctx is done
+func (t *Tar) DecompressContext(ctx context.Context, fileIn string, pathOut string) error {
+	return t.fileDecompressor().DecompressContext(ctx, fileIn, pathOut)
+}
+
+// List returns headers of all entries of an archive fileIn
+func (t *Tar) List(fileIn string) ([]compress.Header, error) {
+	return t.fileDecompressor().List(fileIn)
+}
+
+// ExtractEntry writes contents of an entry with given name of an archive fileIn into w
+func (t *Tar) ExtractEntry(fileIn string, name string, w io.Writer) error {
+	return t.fileDecompressor().ExtractEntry(fileIn, name, w)
+}
+
+// fileCompressor returns compress.FileCompressor for t
+func (t *Tar) fileCompressor() compress.FileCompressor {
+	return compress.FileCompressor{StreamCompressor: t, KeepDirs: t.params.KeepDirs, Progress: t.params.Progress}
+}
+
+// fileDecompressor returns compress.FileDecompressor for t
+func (t *Tar) fileDecompressor() compress.FileDecompressor {
+	return compress.FileDecompressor{StreamDecompressor: t, Limits: t.params.Limits, Progress: t.params.Progress}
+}
+
+// CompressStream writes a tar archive containing sources into w.
+// Since tar headers need entry sizes, sources with zero Size are buffered in memory
+func (t *Tar) CompressStream(w io.Writer, sources ...compress.Source) error {
+	tw := tar.NewWriter(w)
+	for _, source := range sources {
+		if err := writeEntry(tw, source); err != nil {
+			return &compress.Error{Op: "compress", Path: source.Name, Err: err}
+		}
+	}
+	return tw.Close()
+}
+
+// writeEntry writes source into tw
+func writeEntry(tw *tar.Writer, source compress.Source) error {
+	header := &tar.Header{
+		Name:     source.Name,
+		Mode:     int64(source.Mode.Perm()),
+		ModTime:  source.ModTime,
+		Typeflag: tar.TypeReg,
+	}
+	if source.IsDir() {
+		header.Typeflag = tar.TypeDir
+		if !strings.HasSuffix(header.Name, "/") {
+			header.Name += "/"
+		}
+		return tw.WriteHeader(header)
+	}
+	if source.IsSymlink() {
+		header.Typeflag, header.Linkname = tar.TypeSymlink, source.Linkname
+		return tw.WriteHeader(header)
+	}
+
+	r, size := source.Reader, source.Size
+	if size == 0 {
+		b, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		r, size = bytes.NewReader(b), int64(len(b))
+	}
+	header.Size = size
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := io.Copy(tw, r)
+	return err
+}
+
+// DecompressStream calls fn for every entry of a tar archive with given size read from r
+func (t *Tar) DecompressStream(r io.ReaderAt, size int64, fn compress.WalkFunc) error {
+	return Walk(io.NewSectionReader(r, 0, size), fn)
+}
+
+// Walk calls fn for every regular file, directory and symlink entry of a tar archive read from r.
+// Other entry types are skipped
+func Walk(r io.Reader, fn compress.WalkFunc) error {
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		info := header.FileInfo()
+		h := compress.Header{Name: header.Name, Mode: info.Mode(), ModTime: header.ModTime}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			h.Mode |= os.ModeDir
+		case tar.TypeReg:
+			h.Size = header.Size
+		case tar.TypeSymlink:
+			h.Linkname = header.Linkname
+		default:
+			continue
+		}
+		if err := fn(h, tr); err != nil {
+			return err
+		}
+	}
+}
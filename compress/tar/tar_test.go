@@ -0,0 +1,69 @@
+package tar
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mtfelian/utils/compress"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Testing with Ginkgo", func() {
+	It("buffers sources of unknown size", func() {
+		buf := &bytes.Buffer{}
+		Expect(New(Params{}).CompressStream(buf,
+			compress.Source{Header: compress.Header{Name: "a"}, Reader: strings.NewReader("unknown size")},
+		)).To(Succeed())
+
+		tr := tar.NewReader(buf)
+		header, err := tr.Next()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(header.Name).To(Equal("a"))
+		Expect(header.Size).To(BeEquivalentTo(len("unknown size")))
+		Expect(ioutil.ReadAll(tr)).To(Equal([]byte("unknown size")))
+	})
+
+	It("applies default limits", func() {
+		Expect(New(Params{}).params.Limits).To(Equal(compress.DefaultLimits))
+		Expect(New(Params{Limits: compress.Limits{Unlimited: true}}).params.Limits.Unlimited).To(BeTrue())
+
+		tempPath, err := ioutil.TempDir("", "tar")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(tempPath)
+		buf := &bytes.Buffer{}
+		tw := tar.NewWriter(buf)
+		Expect(tw.WriteHeader(&tar.Header{Name: "huge", Typeflag: tar.TypeReg, Size: 2 << 30, Mode: 0600})).To(Succeed())
+		fileIn := filepath.Join(tempPath, "huge.tar")
+		Expect(ioutil.WriteFile(fileIn, buf.Bytes(), 0600)).To(Succeed())
+
+		err = New(Params{}).Decompress(fileIn, filepath.Join(tempPath, "out"))
+		Expect(errors.Is(err, compress.ErrorTooLarge)).To(BeTrue())
+	})
+
+	It("skips entries other than files, directories and symlinks", func() {
+		buf := &bytes.Buffer{}
+		tw := tar.NewWriter(buf)
+		Expect(tw.WriteHeader(&tar.Header{Name: "hardlink", Typeflag: tar.TypeLink, Linkname: "a"})).To(Succeed())
+		Expect(tw.WriteHeader(&tar.Header{Name: "fifo", Typeflag: tar.TypeFifo})).To(Succeed())
+		Expect(tw.WriteHeader(&tar.Header{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "a"})).To(Succeed())
+		Expect(tw.WriteHeader(&tar.Header{Name: "a", Typeflag: tar.TypeReg, Size: 1, Mode: 0600})).To(Succeed())
+		_, err := tw.Write([]byte("a"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tw.Close()).To(Succeed())
+
+		names, linknames := []string{}, []string{}
+		Expect(Walk(buf, func(h compress.Header, r io.Reader) error {
+			names, linknames = append(names, h.Name), append(linknames, h.Linkname)
+			return nil
+		})).To(Succeed())
+		Expect(names).To(Equal([]string{"link", "a"}))
+		Expect(linknames).To(Equal([]string{"a", ""}))
+	})
+})
@@ -0,0 +1,120 @@
+package targz
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+
+	"github.com/mtfelian/utils/compress"
+	"github.com/mtfelian/utils/compress/tar"
+)
+
+// Params is a set of tar.gz compressor parameters
+type Params struct {
+	// KeepDirs preserves directory structure relative to the parent of every path in pathIn.
+	// If false, files are stored by their base names
+	KeepDirs bool
+	Level    int                   // compression level from 1 (best speed) to 9 (best compression), 0 means default
+	Limits   compress.Limits       // decompression limits, compress.DefaultLimits if zero
+	Progress compress.ProgressFunc // reports Compress and Decompress progress, may be nil
+}
+
+// TarGz is a compress.CompressorDecompressor and compress.StreamCompressorDecompressor
+// implementation packing a tar archive with gzip
+type TarGz struct {
+	params Params
+	tar    *tar.Tar
+}
+
+var (
+	_ compress.CompressorDecompressor       = &TarGz{}
+	_ compress.ContextCompressor            = &TarGz{}
+	_ compress.ContextDecompressor          = &TarGz{}
+	_ compress.StreamCompressorDecompressor = &TarGz{}
+	_ compress.Lister                       = &TarGz{}
+	_ compress.EntryExtractor               = &TarGz{}
+)
+
+func init() {
+	compress.Register(compress.Format{
+		Name:       "tar.gz",
+		Extensions: []string{".tar.gz", ".tgz"},
+		Match:      compress.IsTarGz,
+		Archiver:   New(Params{KeepDirs: true, Limits: compress.DefaultLimits}),
+	})
+}
+
+// New returns a new tar.gz compressor/decompressor with given params.
+// Zero Limits are replaced with compress.DefaultLimits
+func New(params Params) *TarGz {
+	params.Limits = params.Limits.OrDefault()
+	return &TarGz{params: params, tar: tar.New(tar.Params{KeepDirs: params.KeepDirs})}
+}
+
+// Compress создаёт архив с путём и именем fileOut из файлов и папок, переданных в pathIn.
+// Папки обходятся рекурсивно. Если при создании архива произошла ошибка, fileOut удаляется
+func (t *TarGz) Compress(fileOut string, pathIn ...string) error {
+	return t.fileCompressor().Compress(fileOut, pathIn...)
+}
+
+// Decompress извлекает из архива с путём и именем fileIn содержимое и помещает его в pathOut.
+// Папка pathOut создаётся, если её не существует
+func (t *TarGz) Decompress(fileIn string, pathOut string) error {
+	return t.fileDecompressor().Decompress(fileIn, pathOut)
+}
+
+// CompressContext is like Compress but stops when ctx is done
+func (t *TarGz) CompressContext(ctx context.Context, fileOut string, pathIn ...string) error {
+	return t.fileCompressor().CompressContext(ctx, fileOut, pathIn...)
+}
+
+// DecompressContext is like Decompress but stops when ctx is done
+func (t *TarGz) DecompressContext(ctx context.Context, fileIn string, pathOut string) error {
+	return t.fileDecompressor().DecompressContext(ctx, fileIn, pathOut)
+}
+
+// List returns headers of all entries of an archive fileIn
+func (t *TarGz) List(fileIn string) ([]compress.Header, error) {
+	return t.fileDecompressor().List(fileIn)
+}
+
+// ExtractEntry writes contents of an entry with given name of an archive fileIn into w
+func (t *TarGz) ExtractEntry(fileIn string, name string, w io.Writer) error {
+	return t.fileDecompressor().ExtractEntry(fileIn, name, w)
+}
+
+// fileCompressor returns compress.FileCompressor for t
+func (t *TarGz) fileCompressor() compress.FileCompressor {
+	return compress.FileCompressor{StreamCompressor: t, KeepDirs: t.params.KeepDirs, Progress: t.params.Progress}
+}
+
+// fileDecompressor returns compress.FileDecompressor for t
+func (t *TarGz) fileDecompressor() compress.FileDecompressor {
+	return compress.FileDecompressor{StreamDecompressor: t, Limits: t.params.Limits, Progress: t.params.Progress}
+}
+
+// CompressStream writes a gzipped tar archive containing sources into w
+func (t *TarGz) CompressStream(w io.Writer, sources ...compress.Source) error {
+	level := t.params.Level
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	gw, err := gzip.NewWriterLevel(w, level)
+	if err != nil {
+		return err
+	}
+	if err := t.tar.CompressStream(gw, sources...); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+// DecompressStream calls fn for every entry of a gzipped tar archive with given size read from r
+func (t *TarGz) DecompressStream(r io.ReaderAt, size int64, fn compress.WalkFunc) error {
+	gr, err := gzip.NewReader(io.NewSectionReader(r, 0, size))
+	if err != nil {
+		return err
+	}
+	defer gr.Close()
+	return tar.Walk(gr, fn)
+}
@@ -0,0 +1,13 @@
+package targz_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestTarGz(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "TarGz Suite")
+}
@@ -0,0 +1,51 @@
+package targz
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mtfelian/utils/compress"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Testing with Ginkgo", func() {
+	It("writes a gzipped tar archive", func() {
+		buf := &bytes.Buffer{}
+		Expect(New(Params{Level: 1}).CompressStream(buf,
+			compress.Source{Header: compress.Header{Name: "a", Size: 1}, Reader: strings.NewReader("a")},
+		)).To(Succeed())
+
+		gr, err := gzip.NewReader(buf)
+		Expect(err).NotTo(HaveOccurred())
+		tr := tar.NewReader(gr)
+		header, err := tr.Next()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(header.Name).To(Equal("a"))
+		Expect(ioutil.ReadAll(tr)).To(Equal([]byte("a")))
+	})
+
+	It("applies default limits", func() {
+		tempPath, err := ioutil.TempDir("", "targz")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(tempPath)
+		fileIn := filepath.Join(tempPath, "bomb.tar.gz")
+		f, err := os.Create(fileIn)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(New(Params{}).CompressStream(f,
+			compress.Source{Header: compress.Header{Name: "bomb", Size: 10 << 20}, Reader: bytes.NewReader(make([]byte, 10<<20))},
+		)).To(Succeed())
+		Expect(f.Close()).To(Succeed())
+
+		err = New(Params{}).Decompress(fileIn, filepath.Join(tempPath, "out"))
+		Expect(errors.Is(err, compress.ErrorRatioExceeded)).To(BeTrue())
+		t := New(Params{Limits: compress.Limits{Unlimited: true}})
+		Expect(t.Decompress(fileIn, filepath.Join(tempPath, "out"))).To(Succeed())
+	})
+})
@@ -0,0 +1,447 @@
+package zip
+
+import (
+	"archive/zip"
+	"compress/flate"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+	"unicode/utf8"
+)
+
+// errors
+var (
+	ErrorPasswordRequired      = errors.New("zip entry is encrypted, password required")
+	ErrorWrongPassword         = errors.New("wrong zip password")
+	ErrorAuthentication        = errors.New("zip entry authentication failed")
+	ErrorUnsupportedEncryption = errors.New("unsupported zip encryption method")
+)
+
+// EncryptionMethod is a method of zip entries encryption
+type EncryptionMethod int
+
+// encryption methods
+const (
+	AES256    EncryptionMethod = iota // WinZip AES-256
+	AES192                            // WinZip AES-192
+	AES128                            // WinZip AES-128
+	ZipCrypto                         // legacy PKWARE encryption, supported for reading only
+)
+
+const (
+	methodAES        = 99     // compression method value marking WinZip AES encrypted entries
+	versionAES       = 51     // zip specification version needed to extract WinZip AES entries
+	extraAES         = 0x9901 // WinZip AES extra field ID
+	extraTime        = 0x5455 // extended timestamp extra field ID
+	flagEncrypted    = 0x1    // general purpose flag bit for encrypted entries
+	flagDescriptor   = 0x8    // general purpose flag bit for entries followed by a data descriptor
+	flagUTF8         = 0x800  // general purpose flag bit for UTF-8 entry names
+	aesVersion       = 2      // AE-2, CRC is not stored
+	aesIterations    = 1000   // PBKDF2 iterations count
+	aesPwvLength     = 2      // password verification value length
+	aesAuthLength    = 10     // authentication code length
+	zipCryptoHeadLen = 12     // ZipCrypto encryption header length
+)
+
+// aesStrengths maps WinZip AES strength codes to key lengths
+var aesStrengths = map[byte]int{1: 16, 2: 24, 3: 32}
+
+// aesStrength returns WinZip AES strength code and key length for the encryption method
+func aesStrength(method EncryptionMethod) (byte, int, error) {
+	codes := map[EncryptionMethod]byte{AES128: 1, AES192: 2, AES256: 3}
+	code, ok := codes[method]
+	if !ok {
+		return 0, 0, ErrorUnsupportedEncryption
+	}
+	return code, aesStrengths[code], nil
+}
+
+// aesKeys derives AES key, HMAC key and password verification value from password and salt
+func aesKeys(password string, salt []byte, keyLen int) (key, authKey, pwv []byte) {
+	derived := pbkdf2SHA1([]byte(password), salt, aesIterations, 2*keyLen+aesPwvLength)
+	return derived[:keyLen], derived[keyLen : 2*keyLen], derived[2*keyLen:]
+}
+
+// pbkdf2SHA1 derives a key of keyLen bytes from password and salt with PBKDF2-HMAC-SHA1 (RFC 8018)
+func pbkdf2SHA1(password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(sha1.New, password)
+	key := make([]byte, 0, keyLen+prf.Size())
+	for block := uint32(1); len(key) < keyLen; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write([]byte{byte(block >> 24), byte(block >> 16), byte(block >> 8), byte(block)})
+		u := prf.Sum(nil)
+		t := append([]byte{}, u...)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:keyLen]
+}
+
+// ctr is AES counter mode with little-endian counter as used by WinZip AES
+type ctr struct {
+	block   cipher.Block
+	counter [aes.BlockSize]byte
+	stream  [aes.BlockSize]byte
+	used    int
+}
+
+// newCTR returns a new WinZip AES counter mode stream with given key
+func newCTR(key []byte) (*ctr, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return &ctr{block: block, used: aes.BlockSize}, nil
+}
+
+// XORKeyStream implements cipher.Stream
+func (c *ctr) XORKeyStream(dst, src []byte) {
+	for i := range src {
+		if c.used == aes.BlockSize {
+			for j := range c.counter {
+				c.counter[j]++
+				if c.counter[j] != 0 {
+					break
+				}
+			}
+			c.block.Encrypt(c.stream[:], c.counter[:])
+			c.used = 0
+		}
+		dst[i] = src[i] ^ c.stream[c.used]
+		c.used++
+	}
+}
+
+// writeAESEntry writes source contents as a WinZip AES encrypted deflated entry.
+// Encrypted data is buffered in a temporary file since its size must be known before writing
+func writeAESEntry(zw *zip.Writer, header *zip.FileHeader, r io.Reader, password string, method EncryptionMethod) error {
+	strength, keyLen, err := aesStrength(method)
+	if err != nil {
+		return err
+	}
+	salt := make([]byte, keyLen/2)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	key, authKey, pwv := aesKeys(password, salt, keyLen)
+	stream, err := newCTR(key)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile("", "zip-aes")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}()
+
+	mac := hmac.New(sha1.New, authKey)
+	encrypted := &countWriter{w: cipher.StreamWriter{S: stream, W: io.MultiWriter(tmp, mac)}}
+	fw, err := flate.NewWriter(encrypted, flate.DefaultCompression)
+	if err != nil {
+		return err
+	}
+	n, err := io.Copy(fw, r)
+	if err != nil {
+		return err
+	}
+	if err := fw.Close(); err != nil {
+		return err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	header.Method, header.Flags = methodAES, header.Flags|flagEncrypted
+	header.CRC32, header.UncompressedSize64 = 0, uint64(n)
+	header.CompressedSize64 = uint64(len(salt) + aesPwvLength + int(encrypted.n) + aesAuthLength)
+	header.Extra = append(header.Extra, aesExtra(strength, zip.Deflate)...)
+	header.ReaderVersion, header.CreatorVersion = versionAES, header.CreatorVersion&0xff00|versionAES
+	if utf8.ValidString(header.Name) && hasMultibyte(header.Name) {
+		header.Flags |= flagUTF8
+	}
+	if !header.Modified.IsZero() {
+		header.ModifiedTime, header.ModifiedDate = msDosTime(header.Modified)
+		header.Extra = append(header.Extra, timeExtra(header.Modified)...)
+	}
+
+	w, err := zw.CreateRaw(header)
+	if err != nil {
+		return err
+	}
+	for _, chunk := range [][]byte{salt, pwv} {
+		if _, err := w.Write(chunk); err != nil {
+			return err
+		}
+	}
+	if _, err := io.Copy(w, tmp); err != nil {
+		return err
+	}
+	_, err = w.Write(mac.Sum(nil)[:aesAuthLength])
+	return err
+}
+
+// aesExtra returns WinZip AES extra field
+func aesExtra(strength byte, method uint16) []byte {
+	b := make([]byte, 11)
+	binary.LittleEndian.PutUint16(b[0:], extraAES)
+	binary.LittleEndian.PutUint16(b[2:], 7)
+	binary.LittleEndian.PutUint16(b[4:], aesVersion)
+	copy(b[6:], "AE")
+	b[8] = strength
+	binary.LittleEndian.PutUint16(b[9:], method)
+	return b
+}
+
+// parseAESExtra finds WinZip AES extra field and returns key length and actual compression method
+func parseAESExtra(extra []byte) (int, uint16, error) {
+	for len(extra) >= 4 {
+		id, size := binary.LittleEndian.Uint16(extra), int(binary.LittleEndian.Uint16(extra[2:]))
+		extra = extra[4:]
+		if size > len(extra) {
+			break
+		}
+		if id == extraAES && size >= 7 {
+			keyLen, ok := aesStrengths[extra[4]]
+			if !ok {
+				return 0, 0, ErrorUnsupportedEncryption
+			}
+			return keyLen, binary.LittleEndian.Uint16(extra[5:]), nil
+		}
+		extra = extra[size:]
+	}
+	return 0, 0, ErrorUnsupportedEncryption
+}
+
+// openEncrypted opens an encrypted zip entry f using password
+func openEncrypted(f *zip.File, password string) (io.ReadCloser, error) {
+	if password == "" {
+		return nil, ErrorPasswordRequired
+	}
+	raw, err := f.OpenRaw()
+	if err != nil {
+		return nil, err
+	}
+	if f.Method == methodAES {
+		return openAES(f, raw, password)
+	}
+	return openZipCrypto(f, raw, password)
+}
+
+// openAES opens WinZip AES encrypted entry f with raw data read from raw
+func openAES(f *zip.File, raw io.Reader, password string) (io.ReadCloser, error) {
+	keyLen, method, err := parseAESExtra(f.Extra)
+	if err != nil {
+		return nil, err
+	}
+	head := make([]byte, keyLen/2+aesPwvLength)
+	if _, err := io.ReadFull(raw, head); err != nil {
+		return nil, err
+	}
+	key, authKey, pwv := aesKeys(password, head[:keyLen/2], keyLen)
+	if subtle.ConstantTimeCompare(pwv, head[keyLen/2:]) != 1 {
+		return nil, ErrorWrongPassword
+	}
+	stream, err := newCTR(key)
+	if err != nil {
+		return nil, err
+	}
+
+	dataLen := int64(f.CompressedSize64) - int64(len(head)) - aesAuthLength
+	if dataLen < 0 {
+		return nil, zip.ErrFormat
+	}
+	mac := hmac.New(sha1.New, authKey)
+	data := &authReader{
+		r:    io.TeeReader(io.LimitReader(raw, dataLen), mac),
+		raw:  raw,
+		mac:  mac,
+		left: dataLen,
+	}
+	return decompressor(method, cipher.StreamReader{S: stream, R: data})
+}
+
+// authReader reads encrypted data and checks WinZip AES authentication code after it
+type authReader struct {
+	r    io.Reader
+	raw  io.Reader
+	mac  hash.Hash
+	left int64
+}
+
+// Read implements io.Reader
+func (r *authReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.left -= int64(n)
+	if err != io.EOF {
+		return n, err
+	}
+	if r.left > 0 {
+		return n, io.ErrUnexpectedEOF
+	}
+	code := make([]byte, aesAuthLength)
+	if _, err := io.ReadFull(r.raw, code); err != nil {
+		return n, err
+	}
+	if !hmac.Equal(code, r.mac.Sum(nil)[:aesAuthLength]) {
+		return n, ErrorAuthentication
+	}
+	return n, io.EOF
+}
+
+// openZipCrypto opens PKWARE traditional encrypted entry f with raw data read from raw
+func openZipCrypto(f *zip.File, raw io.Reader, password string) (io.ReadCloser, error) {
+	keys := newZipCryptoKeys(password)
+	head := make([]byte, zipCryptoHeadLen)
+	if _, err := io.ReadFull(raw, head); err != nil {
+		return nil, err
+	}
+	keys.decrypt(head)
+	check := byte(f.CRC32 >> 24)
+	if f.Flags&flagDescriptor != 0 {
+		check = byte(f.ModifiedTime >> 8)
+	}
+	if head[zipCryptoHeadLen-1] != check {
+		return nil, ErrorWrongPassword
+	}
+
+	dataLen := int64(f.CompressedSize64) - zipCryptoHeadLen
+	rc, err := decompressor(f.Method, &zipCryptoReader{r: io.LimitReader(raw, dataLen), keys: keys})
+	if err != nil {
+		return nil, err
+	}
+	return &crcReader{rc: rc, hash: crc32.NewIEEE(), crc: f.CRC32}, nil
+}
+
+// zipCryptoKeys is a state of PKWARE traditional encryption
+type zipCryptoKeys [3]uint32
+
+// newZipCryptoKeys initializes traditional encryption keys with password
+func newZipCryptoKeys(password string) *zipCryptoKeys {
+	keys := &zipCryptoKeys{0x12345678, 0x23456789, 0x34567890}
+	for _, b := range []byte(password) {
+		keys.update(b)
+	}
+	return keys
+}
+
+// update updates keys with plain text byte b
+func (k *zipCryptoKeys) update(b byte) {
+	k[0] = crc32.IEEETable[byte(k[0])^b] ^ (k[0] >> 8)
+	k[1] = (k[1]+(k[0]&0xff))*134775813 + 1
+	k[2] = crc32.IEEETable[byte(k[2])^byte(k[1]>>24)] ^ (k[2] >> 8)
+}
+
+// decrypt decrypts b in place
+func (k *zipCryptoKeys) decrypt(b []byte) {
+	for i := range b {
+		t := k[2] | 2
+		b[i] ^= byte((t * (t ^ 1)) >> 8)
+		k.update(b[i])
+	}
+}
+
+// zipCryptoReader decrypts data read from r
+type zipCryptoReader struct {
+	r    io.Reader
+	keys *zipCryptoKeys
+}
+
+// Read implements io.Reader
+func (r *zipCryptoReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.keys.decrypt(p[:n])
+	return n, err
+}
+
+// crcReader checks CRC32 of data read from rc on EOF
+type crcReader struct {
+	rc   io.ReadCloser
+	hash hash.Hash32
+	crc  uint32
+}
+
+// Read implements io.Reader
+func (r *crcReader) Read(p []byte) (int, error) {
+	n, err := r.rc.Read(p)
+	r.hash.Write(p[:n])
+	if err == io.EOF && r.hash.Sum32() != r.crc {
+		return n, zip.ErrChecksum
+	}
+	return n, err
+}
+
+// Close implements io.Closer
+func (r *crcReader) Close() error { return r.rc.Close() }
+
+// decompressor returns a reader decompressing data read from r with zip method
+func decompressor(method uint16, r io.Reader) (io.ReadCloser, error) {
+	switch method {
+	case zip.Store:
+		return ioutil.NopCloser(r), nil
+	case zip.Deflate:
+		return flate.NewReader(r), nil
+	}
+	return nil, zip.ErrAlgorithm
+}
+
+// countWriter counts bytes written to w
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+// Write implements io.Writer
+func (w *countWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+// timeExtra returns extended timestamp extra field with modification time t
+func timeExtra(t time.Time) []byte {
+	b := make([]byte, 9)
+	binary.LittleEndian.PutUint16(b[0:], extraTime)
+	binary.LittleEndian.PutUint16(b[2:], 5)
+	b[4] = 1
+	binary.LittleEndian.PutUint32(b[5:], uint32(t.Unix()))
+	return b
+}
+
+// hasMultibyte returns true if s contains non-ASCII characters
+func hasMultibyte(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return true
+		}
+	}
+	return false
+}
+
+// msDosTime converts t to MS-DOS time and date
+func msDosTime(t time.Time) (uint16, uint16) {
+	return uint16(t.Hour()<<11 | t.Minute()<<5 | t.Second()>>1),
+		uint16((t.Year()-1980)<<9 | int(t.Month())<<5 | t.Day())
+}
@@ -0,0 +1,206 @@
+package zip
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/mtfelian/utils/compress"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// zipCryptoArchive is created by Info-ZIP with `zip -P secret legacy.zip legacy.txt`
+const zipCryptoArchive = `
+UEsDBBQACQAIAABQAU/Nk+6+IQAAAC0AAAAKABwAbGVnYWN5LnR4dFVUCQADILhCXSC4Ql11eAsA
+AQQAAAAABAAAAAAuSuTsYrexhPDkzcnsxFJeWH/0D7rn4jybSy8kN7lgq61QSwcIzZPuviEAAAAt
+AAAAUEsBAh4DFAAJAAgAAFABT82T7r4hAAAALQAAAAoAGAAAAAAAAQAAAKSBAAAAAGxlZ2FjeS50
+eHRVVAUAAyC4Ql11eAsAAQQAAAAABAAAAABQSwUGAAAAAAEAAQBQAAAAdQAAAAAA`
+
+var _ = Describe("encryption", func() {
+	data := strings.Repeat("secret data ", 1000)
+
+	BeforeEach(func() {
+		var err error
+		tempPath, err = ioutil.TempDir("", "zip")
+		Expect(err).NotTo(HaveOccurred())
+	})
+	AfterEach(func() { Expect(removeTestFiles()).To(Succeed()) })
+
+	compressToBytes := func(z *Zip) []byte {
+		buf := &bytes.Buffer{}
+		Expect(z.CompressStream(buf,
+			compress.Source{Header: compress.Header{Name: "dir/"}},
+			compress.Source{Header: compress.Header{Name: "dir/secret.txt", Mode: 0600}, Reader: strings.NewReader(data)},
+		)).To(Succeed())
+		return buf.Bytes()
+	}
+
+	readAll := func(z *Zip, archive []byte) (map[string]string, error) {
+		contents := map[string]string{}
+		err := z.DecompressStream(bytes.NewReader(archive), int64(len(archive)), func(h compress.Header, r io.Reader) error {
+			b, err := ioutil.ReadAll(r)
+			contents[h.Name] = string(b)
+			return err
+		})
+		return contents, err
+	}
+
+	It("encrypts and decrypts with AES", func() {
+		for _, method := range []EncryptionMethod{AES256, AES192, AES128} {
+			By(fmt.Sprintf("testing case %d", method))
+			z := New(Params{Password: "пароль", Encryption: method})
+			archive := compressToBytes(z)
+			Expect(bytes.Contains(archive, []byte("secret data"))).To(BeFalse())
+
+			zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+			Expect(err).NotTo(HaveOccurred())
+			_, err = zr.File[1].Open()
+			Expect(err).To(MatchError(zip.ErrAlgorithm))
+
+			contents, err := readAll(z, archive)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(contents).To(Equal(map[string]string{"dir/": "", "dir/secret.txt": data}))
+		}
+	})
+
+	It("fails with wrong or missing password", func() {
+		archive := compressToBytes(New(Params{Password: "right"}))
+
+		_, err := readAll(New(Params{Password: "wrong"}), archive)
+		Expect(errors.Is(err, ErrorWrongPassword)).To(BeTrue())
+		_, err = readAll(New(Params{}), archive)
+		Expect(errors.Is(err, ErrorPasswordRequired)).To(BeTrue())
+	})
+
+	It("detects tampered data", func() {
+		archive := compressToBytes(New(Params{Password: "right"}))
+		zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+		Expect(err).NotTo(HaveOccurred())
+		offset, err := zr.File[1].DataOffset()
+		Expect(err).NotTo(HaveOccurred())
+		archive[offset+20] ^= 0xff
+
+		_, err = readAll(New(Params{Password: "right"}), archive)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("detects truncated data of stored entries", func() {
+		strength, keyLen, err := aesStrength(AES256)
+		Expect(err).NotTo(HaveOccurred())
+		salt := make([]byte, keyLen/2)
+		key, authKey, pwv := aesKeys("right", salt, keyLen)
+		stream, err := newCTR(key)
+		Expect(err).NotTo(HaveOccurred())
+		encrypted := []byte(data)
+		stream.XORKeyStream(encrypted, encrypted)
+		mac := hmac.New(sha1.New, authKey)
+		mac.Write(encrypted)
+
+		buf := &bytes.Buffer{}
+		zw := zip.NewWriter(buf)
+		w, err := zw.CreateRaw(&zip.FileHeader{
+			Name:               "stored.txt",
+			Method:             methodAES,
+			Flags:              flagEncrypted,
+			CompressedSize64:   uint64(len(salt) + len(pwv) + len(encrypted) + aesAuthLength),
+			UncompressedSize64: uint64(len(data)),
+			Extra:              aesExtra(strength, zip.Store),
+		})
+		Expect(err).NotTo(HaveOccurred())
+		for _, b := range [][]byte{salt, pwv, encrypted, mac.Sum(nil)[:aesAuthLength]} {
+			_, err = w.Write(b)
+			Expect(err).NotTo(HaveOccurred())
+		}
+		Expect(zw.Close()).To(Succeed())
+		archive := buf.Bytes()
+
+		contents, err := readAll(New(Params{Password: "right"}), archive)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(contents["stored.txt"]).To(Equal(data))
+
+		zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+		Expect(err).NotTo(HaveOccurred())
+		offset, err := zr.File[0].DataOffset()
+		Expect(err).NotTo(HaveOccurred())
+		truncated := &truncatedReaderAt{
+			r:     bytes.NewReader(archive),
+			start: offset,
+			from:  offset + int64(len(salt)+len(pwv)) + 100,
+			end:   offset + int64(zr.File[0].CompressedSize64),
+		}
+		err = New(Params{Password: "right"}).DecompressStream(truncated, int64(len(archive)), func(h compress.Header, r io.Reader) error {
+			_, err := ioutil.ReadAll(r)
+			return err
+		})
+		Expect(errors.Is(err, io.ErrUnexpectedEOF)).To(BeTrue(), "%v", err)
+	})
+
+	It("reads ZipCrypto archives", func() {
+		archive, err := base64.StdEncoding.DecodeString(strings.TrimSpace(zipCryptoArchive))
+		Expect(err).NotTo(HaveOccurred())
+
+		contents, err := readAll(New(Params{Password: "secret"}), archive)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(contents).To(Equal(map[string]string{
+			"legacy.txt": "zipcrypto data zipcrypto data zipcrypto data\n",
+		}))
+
+		_, err = readAll(New(Params{Password: "wrong"}), archive)
+		Expect(errors.Is(err, ErrorWrongPassword) || errors.Is(err, zip.ErrChecksum)).To(BeTrue())
+	})
+
+	It("lists encrypted archives without password", func() {
+		fileIn := filepath.Join(tempPath, "encrypted-list.zip")
+		Expect(ioutil.WriteFile(fileIn, compressToBytes(New(Params{Password: "right"})), 0600)).To(Succeed())
+
+		headers, err := New(Params{}).List(fileIn)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(headers).To(HaveLen(2))
+		Expect(headers[1].Name).To(Equal("dir/secret.txt"))
+		Expect(headers[1].Size).To(BeEquivalentTo(len(data)))
+
+		buf := &bytes.Buffer{}
+		Expect(errors.Is(New(Params{}).ExtractEntry(fileIn, "dir/secret.txt", buf), ErrorPasswordRequired)).To(BeTrue())
+		Expect(New(Params{Password: "right"}).ExtractEntry(fileIn, "dir/secret.txt", buf)).To(Succeed())
+		Expect(buf.String()).To(Equal(data))
+	})
+
+	It("refuses to write ZipCrypto", func() {
+		err := New(Params{Password: "secret", Encryption: ZipCrypto}).CompressStream(&bytes.Buffer{},
+			compress.Source{Header: compress.Header{Name: "a"}, Reader: strings.NewReader("a")})
+		Expect(errors.Is(err, ErrorUnsupportedEncryption)).To(BeTrue())
+	})
+})
+
+// truncatedReaderAt reads from r as if data of an entry stored in [start, end) were cut at from,
+// just like in a truncated file. Reads starting outside of the entry are not changed
+type truncatedReaderAt struct {
+	r                io.ReaderAt
+	start, from, end int64
+}
+
+// ReadAt implements io.ReaderAt
+func (t *truncatedReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	switch {
+	case off < t.start || off >= t.end:
+		return t.r.ReadAt(p, off)
+	case off >= t.from:
+		return 0, io.EOF
+	case off+int64(len(p)) > t.from:
+		n, err := t.r.ReadAt(p[:t.from-off], off)
+		if err == nil {
+			err = io.EOF
+		}
+		return n, err
+	}
+	return t.r.ReadAt(p, off)
+}
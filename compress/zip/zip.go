@@ -1,32 +1,277 @@
 package zip
 
 import (
-	"os"
-	"os/exec"
+	"archive/zip"
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"strings"
 
 	"github.com/mtfelian/utils"
+	"github.com/mtfelian/utils/compress"
 )
 
-const (
-	zipPath   = "zip"
-	unzipPath = "unzip"
+// ошибки
+var (
+	ErrorNoInput        = compress.ErrorNoInput
+	ErrorDuplicateEntry = compress.ErrorDuplicateEntry
+	ErrorInvalidEntry   = compress.ErrorInvalidEntry
 )
 
-// Compress создаёт архив с путём и именем fileOut из файлов и папок, переданных в pathIn
-func Compress(fileOut string, pathIn ...string) error {
-	if utils.FileExists(fileOut) {
-		if err := os.Remove(fileOut); err != nil {
+// Error описывает ошибку при обработке конкретного файла или элемента архива
+type Error = compress.Error
+
+// maxLinknameLength ограничивает длину цели символической ссылки, читаемой из элемента архива
+const maxLinknameLength = 4096
+
+// Params задаёт параметры zip-архиватора
+type Params struct {
+	// KeepDirs сохраняет структуру папок относительно родителя каждого пути из pathIn.
+	// Если false, файлы сохраняются только по именам, как это делает `zip -j`
+	KeepDirs bool
+	Limits   compress.Limits // ограничения распаковки, compress.DefaultLimits, если не заданы
+	// Password используется для шифрования записываемых и расшифровки читаемых элементов.
+	// Пустой пароль отключает шифрование
+	Password string
+	// Encryption задаёт метод шифрования записываемых элементов, по умолчанию AES256.
+	// Зашифрованные элементы читаются тем методом, которым были записаны
+	Encryption EncryptionMethod
+	Progress   compress.ProgressFunc // сообщает о ходе Compress и Decompress, может быть nil
+}
+
+// Zip реализует compress.CompressorDecompressor и compress.StreamCompressorDecompressor
+// на основе archive/zip
+type Zip struct{ params Params }
+
+var (
+	_ compress.CompressorDecompressor       = &Zip{}
+	_ compress.ContextCompressor            = &Zip{}
+	_ compress.ContextDecompressor          = &Zip{}
+	_ compress.StreamCompressorDecompressor = &Zip{}
+	_ compress.Lister                       = &Zip{}
+	_ compress.EntryExtractor               = &Zip{}
+)
+
+func init() {
+	compress.Register(compress.Format{
+		Name:       "zip",
+		Extensions: []string{".zip"},
+		Match:      compress.IsZip,
+		Archiver:   New(Params{KeepDirs: true, Limits: compress.DefaultLimits}),
+	})
+}
+
+// New возвращает новый zip-архиватор с параметрами params.
+// Незаданные Limits заменяются на compress.DefaultLimits
+func New(params Params) *Zip {
+	params.Limits = params.Limits.OrDefault()
+	return &Zip{params: params}
+}
+
+// Compress создаёт архив с путём и именем fileOut из файлов и папок, переданных в pathIn.
+// Папки обходятся рекурсивно. Если при создании архива произошла ошибка, fileOut удаляется
+func (z *Zip) Compress(fileOut string, pathIn ...string) error {
+	return z.fileCompressor().Compress(fileOut, pathIn...)
+}
+
+// Decompress извлекает из архива с путём и именем fileIn содержимое и помещает его в pathOut.
+// Папка pathOut создаётся, если её не существует
+func (z *Zip) Decompress(fileIn string, pathOut string) error {
+	return z.fileDecompressor().Decompress(fileIn, pathOut)
+}
+
+// CompressContext аналогичен Compress, но прерывается при завершении ctx
+func (z *Zip) CompressContext(ctx context.Context, fileOut string, pathIn ...string) error {
+	return z.fileCompressor().CompressContext(ctx, fileOut, pathIn...)
+}
+
+// DecompressContext аналогичен Decompress, но прерывается при завершении ctx
+func (z *Zip) DecompressContext(ctx context.Context, fileIn string, pathOut string) error {
+	return z.fileDecompressor().DecompressContext(ctx, fileIn, pathOut)
+}
+
+// fileCompressor возвращает compress.FileCompressor для z
+func (z *Zip) fileCompressor() compress.FileCompressor {
+	return compress.FileCompressor{StreamCompressor: z, KeepDirs: z.params.KeepDirs, Progress: z.params.Progress}
+}
+
+// fileDecompressor возвращает compress.FileDecompressor для z
+func (z *Zip) fileDecompressor() compress.FileDecompressor {
+	return compress.FileDecompressor{StreamDecompressor: z, Limits: z.params.Limits, Progress: z.params.Progress}
+}
+
+// CompressStream записывает в w zip-архив из sources
+func (z *Zip) CompressStream(w io.Writer, sources ...compress.Source) error {
+	zw := zip.NewWriter(w)
+	for _, source := range sources {
+		if err := z.writeEntry(zw, source); err != nil {
+			return &Error{Op: "compress", Path: source.Name, Err: err}
+		}
+	}
+	return zw.Close()
+}
+
+// writeEntry записывает source в zw
+func (z *Zip) writeEntry(zw *zip.Writer, source compress.Source) error {
+	header := &zip.FileHeader{Name: source.Name, Modified: source.ModTime}
+	header.SetMode(source.Mode)
+	if source.IsDir() {
+		if !strings.HasSuffix(header.Name, "/") {
+			header.Name += "/"
+		}
+		_, err := zw.CreateHeader(header)
+		return err
+	}
+	if source.IsSymlink() {
+		entry, err := zw.CreateHeader(header)
+		if err != nil {
 			return err
 		}
+		_, err = io.WriteString(entry, source.Linkname)
+		return err
+	}
+	if z.params.Password != "" {
+		return writeAESEntry(zw, header, source.Reader, z.params.Password, z.params.Encryption)
 	}
-	cmdParams := append([]string{"-j", fileOut}, pathIn...)
-	_, err := exec.Command(zipPath, cmdParams...).CombinedOutput()
+	header.Method = zip.Deflate
+
+	entry, err := zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(entry, source.Reader)
 	return err
 }
 
-// Decompress извлекает из архива с путём и именем fileIn содержимое и помещает его в pathOut
+// DecompressStream вызывает fn для каждого элемента zip-архива размером size, читаемого из r
+func (z *Zip) DecompressStream(r io.ReaderAt, size int64, fn compress.WalkFunc) error {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return err
+	}
+	for _, f := range zr.File {
+		if err := z.walkEntry(f, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// walkEntry открывает элемент архива f и передаёт его в fn
+func (z *Zip) walkEntry(f *zip.File, fn compress.WalkFunc) error {
+	header, err := z.header(f)
+	if err != nil {
+		return err
+	}
+	if header.IsDir() || header.IsSymlink() {
+		return fn(header, bytes.NewReader(nil))
+	}
+
+	rc, err := z.open(f)
+	if err != nil {
+		return &Error{Op: "decompress", Path: f.Name, Err: err}
+	}
+	defer rc.Close()
+	return fn(header, rc)
+}
+
+// header возвращает compress.Header для элемента архива f. Цели символических ссылок читаются из содержимого элементов
+func (z *Zip) header(f *zip.File) (compress.Header, error) {
+	header := compress.Header{
+		Name:    f.Name,
+		Size:    int64(f.UncompressedSize64),
+		Mode:    f.Mode(),
+		ModTime: f.Modified,
+	}
+	if !header.IsSymlink() {
+		return header, nil
+	}
+
+	rc, err := z.open(f)
+	if err != nil {
+		return header, &Error{Op: "decompress", Path: f.Name, Err: err}
+	}
+	defer rc.Close()
+	linkname, err := ioutil.ReadAll(io.LimitReader(rc, maxLinknameLength))
+	if err != nil {
+		return header, &Error{Op: "decompress", Path: f.Name, Err: err}
+	}
+	header.Size, header.Linkname = 0, string(linkname)
+	return header, nil
+}
+
+// List возвращает заголовки всех элементов архива fileIn, прочитанные из центрального каталога zip
+func (z *Zip) List(fileIn string) ([]compress.Header, error) {
+	zr, err := zip.OpenReader(fileIn)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	headers := make([]compress.Header, 0, len(zr.File))
+	for _, f := range zr.File {
+		header, err := z.header(f)
+		if err != nil {
+			return nil, err
+		}
+		headers = append(headers, header)
+	}
+	return headers, nil
+}
+
+// ExtractEntry записывает в w содержимое элемента-файла name архива fileIn.
+// Элемент находится по центральному каталогу zip без чтения архива целиком
+func (z *Zip) ExtractEntry(fileIn string, name string, w io.Writer) error {
+	zr, err := zip.OpenReader(fileIn)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	for _, f := range zr.File {
+		if f.Name != name {
+			continue
+		}
+		header, err := z.header(f)
+		if err != nil {
+			return err
+		}
+		if header.IsDir() || header.IsSymlink() {
+			return compress.ErrorNotAFile
+		}
+		rc, err := z.open(f)
+		if err != nil {
+			return &Error{Op: "decompress", Path: f.Name, Err: err}
+		}
+		defer rc.Close()
+
+		return z.params.Limits.Guard(utils.FileSize(fileIn), func(h compress.Header, r io.Reader) error {
+			if _, err := io.Copy(w, r); err != nil {
+				return &Error{Op: "decompress", Path: h.Name, Err: err}
+			}
+			return nil
+		})(header, rc)
+	}
+	return compress.ErrorEntryNotFound
+}
+
+// open открывает элемент архива f, при необходимости расшифровывая его
+func (z *Zip) open(f *zip.File) (io.ReadCloser, error) {
+	if f.Flags&flagEncrypted != 0 {
+		return openEncrypted(f, z.params.Password)
+	}
+	return f.Open()
+}
+
+// Compress создаёт архив с путём и именем fileOut из файлов и папок, переданных в pathIn,
+// не сохраняя структуру папок
+func Compress(fileOut string, pathIn ...string) error {
+	return New(Params{}).Compress(fileOut, pathIn...)
+}
+
+// Decompress извлекает из архива с путём и именем fileIn содержимое и помещает его в pathOut.
+// Применяются ограничения compress.DefaultLimits
 func Decompress(fileIn string, pathOut string) error {
-	cmdParams := []string{fileIn, "-d", pathOut}
-	_, err := exec.Command(unzipPath, cmdParams...).CombinedOutput()
-	return err
+	return New(Params{}).Decompress(fileIn, pathOut)
 }
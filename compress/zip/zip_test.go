@@ -1,95 +1,222 @@
 package zip
 
 import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/mtfelian/utils"
+	"github.com/mtfelian/utils/compress"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
 
 var _ = Describe("Testing with Ginkgo", func() {
 	BeforeEach(func() { createTestFiles() })
-	AfterEach(func() { os.RemoveAll(testPath) })
+	AfterEach(func() { Expect(removeTestFiles()).To(Succeed()) })
 
 	It("checks Compress/Decompress", func() {
-		binPath, err := utils.GetSelfPath()
-		Expect(err).NotTo(HaveOccurred())
+		outputPath := filepath.Join(tempPath, "output.zip")
+		Expect(Compress(outputPath, filepath.Join(testPath, "file1"), filepath.Join(testPath, "dir1"))).To(Succeed())
+		Expect(utils.FileExists(outputPath)).To(BeTrue())
 
-		outputPath := filepath.Join(binPath, "output.zip")
-		testFilesFullPaths := []string{}
-		for _, f := range testFiles {
-			testFilesFullPaths = append(testFilesFullPaths, filepath.Join(testPath, f))
+		extractPath := filepath.Join(tempPath, "extracted")
+		Expect(Decompress(outputPath, extractPath)).To(Succeed())
+
+		for path, data := range map[string]string{"file1": "file1data", "file2": "file2data"} {
+			By(fmt.Sprintf("testing case %s", path))
+			Expect(ioutil.ReadFile(filepath.Join(extractPath, path))).To(Equal([]byte(data)))
 		}
+		Expect(filepath.Join(extractPath, "dir1")).NotTo(BeADirectory())
+	})
 
-		Expect(Compress(outputPath, testFilesFullPaths...)).To(Succeed())
-		Expect(utils.FileExists(outputPath)).To(BeTrue())
-		Expect(removeTestFiles()).To(Succeed())
-		Expect(Decompress(outputPath, "/")).To(Succeed())
+	It("keeps directory structure with KeepDirs", func() {
+		z := New(Params{KeepDirs: true})
+		outputPath := filepath.Join(tempPath, "output.zip")
+		Expect(z.Compress(outputPath, testPath)).To(Succeed())
 
-		for _, path := range testFilesFullPaths {
-			By(fmt.Sprintf("testing case %s", path))
-			Expect(utils.FileExists(path)).To(BeTrue())
-			Expect(readData(&decompressedData)).To(Succeed())
-			sourceLastElement := sourceData[len(sourceData)-1]
-			decompressedLastElement := decompressedData[len(decompressedData)-1]
-			Expect(decompressedLastElement).To(Equal(sourceLastElement))
+		extractPath := filepath.Join(tempPath, "extracted")
+		Expect(z.Decompress(outputPath, extractPath)).To(Succeed())
+
+		for _, f := range testFiles {
+			By(fmt.Sprintf("testing case %s", f))
+			p := filepath.Join(testPath, f)
+			isDir, err := utils.IsDir(p)
+			Expect(err).NotTo(HaveOccurred())
+			extracted := filepath.Join(extractPath, testDir, f)
+			if isDir {
+				Expect(extracted).To(BeADirectory())
+				continue
+			}
+			Expect(ioutil.ReadFile(extracted)).To(Equal(sourceData[f]))
 		}
 	})
+
+	It("checks CompressContext/DecompressContext with Progress", func() {
+		var last compress.Progress
+		z := New(Params{Progress: func(p compress.Progress) { last = p }})
+		outputPath := filepath.Join(tempPath, "output.zip")
+		Expect(z.CompressContext(context.Background(), outputPath, filepath.Join(testPath, "file1"))).To(Succeed())
+		Expect(last).To(Equal(compress.Progress{Entries: 1, Bytes: int64(len("file1data"))}))
+
+		last = compress.Progress{}
+		extractPath := filepath.Join(tempPath, "extracted")
+		Expect(z.DecompressContext(context.Background(), outputPath, extractPath)).To(Succeed())
+		Expect(last).To(Equal(compress.Progress{Entries: 1, Bytes: int64(len("file1data")), Entry: "file1"}))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		err := z.DecompressContext(ctx, outputPath, filepath.Join(tempPath, "cancelled"))
+		Expect(errors.Is(err, context.Canceled)).To(BeTrue())
+		Expect(filepath.Join(tempPath, "cancelled", "file1")).NotTo(BeAnExistingFile())
+	})
+
+	It("checks CompressStream/DecompressStream", func() {
+		z, buf := New(Params{}), &bytes.Buffer{}
+		modTime := time.Date(2019, 8, 1, 10, 0, 0, 0, time.UTC)
+		Expect(z.CompressStream(buf,
+			compress.Source{Header: compress.Header{Name: "dir/", Mode: os.ModeDir | 0755, ModTime: modTime}},
+			compress.Source{
+				Header: compress.Header{Name: "dir/a.txt", Mode: 0640, ModTime: modTime},
+				Reader: strings.NewReader("a data"),
+			},
+		)).To(Succeed())
+
+		headers, contents := []compress.Header{}, []string{}
+		Expect(z.DecompressStream(bytes.NewReader(buf.Bytes()), int64(buf.Len()),
+			func(h compress.Header, r io.Reader) error {
+				b, err := ioutil.ReadAll(r)
+				headers, contents = append(headers, h), append(contents, string(b))
+				return err
+			})).To(Succeed())
+
+		Expect(headers).To(HaveLen(2))
+		Expect(headers[0].IsDir()).To(BeTrue())
+		Expect(headers[1].Name).To(Equal("dir/a.txt"))
+		Expect(headers[1].Size).To(BeEquivalentTo(len("a data")))
+		Expect(headers[1].Mode.Perm()).To(Equal(os.FileMode(0640)))
+		Expect(headers[1].ModTime.Equal(modTime)).To(BeTrue())
+		Expect(contents).To(Equal([]string{"", "a data"}))
+	})
+
+	It("stops DecompressStream on WalkFunc error", func() {
+		outputPath := filepath.Join(tempPath, "output.zip")
+		Expect(Compress(outputPath, filepath.Join(testPath, "file1"), filepath.Join(testPath, "dir1"))).To(Succeed())
+		f, err := os.Open(outputPath)
+		Expect(err).NotTo(HaveOccurred())
+		defer f.Close()
+
+		stopErr, calls := errors.New("stop"), 0
+		Expect(New(Params{}).DecompressStream(f, utils.FileSize(outputPath), func(compress.Header, io.Reader) error {
+			calls++
+			return stopErr
+		})).To(MatchError(stopErr))
+		Expect(calls).To(Equal(1))
+	})
+
+	It("fails on duplicate entry names", func() {
+		duplicatePath := filepath.Join(tempPath, "file1")
+		Expect(ioutil.WriteFile(duplicatePath, []byte("other"), 0660)).To(Succeed())
+
+		outputPath := filepath.Join(tempPath, "output.zip")
+		err := Compress(outputPath, filepath.Join(testPath, "file1"), duplicatePath)
+		Expect(errors.Is(err, ErrorDuplicateEntry)).To(BeTrue())
+		var zipErr *Error
+		Expect(errors.As(err, &zipErr)).To(BeTrue())
+		Expect(zipErr.Path).To(Equal(duplicatePath))
+		Expect(utils.FileExists(outputPath)).To(BeFalse())
+	})
+
+	It("fails without input", func() {
+		Expect(Compress(filepath.Join(tempPath, "output.zip"))).To(MatchError(ErrorNoInput))
+	})
+
+	It("fails on missing input", func() {
+		outputPath := filepath.Join(tempPath, "output.zip")
+		err := Compress(outputPath, filepath.Join(tempPath, "missing"))
+		Expect(os.IsNotExist(errors.Unwrap(err))).To(BeTrue())
+		Expect(utils.FileExists(outputPath)).To(BeFalse())
+	})
+
+	It("refuses to extract entries outside pathOut", func() {
+		outputPath := filepath.Join(tempPath, "evil.zip")
+		f, err := os.Create(outputPath)
+		Expect(err).NotTo(HaveOccurred())
+		w := zip.NewWriter(f)
+		entry, err := w.Create("../evil")
+		Expect(err).NotTo(HaveOccurred())
+		_, err = entry.Write([]byte("evil"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(w.Close()).To(Succeed())
+		Expect(f.Close()).To(Succeed())
+
+		err = Decompress(outputPath, filepath.Join(tempPath, "extracted"))
+		Expect(errors.Is(err, ErrorInvalidEntry)).To(BeTrue())
+		Expect(utils.FileExists(filepath.Join(tempPath, "evil"))).To(BeFalse())
+	})
+
+	It("applies default limits", func() {
+		outputPath := filepath.Join(tempPath, "bomb.zip")
+		f, err := os.Create(outputPath)
+		Expect(err).NotTo(HaveOccurred())
+		w := zip.NewWriter(f)
+		entry, err := w.Create("bomb")
+		Expect(err).NotTo(HaveOccurred())
+		_, err = entry.Write(make([]byte, 10<<20))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(w.Close()).To(Succeed())
+		Expect(f.Close()).To(Succeed())
+
+		err = Decompress(outputPath, filepath.Join(tempPath, "extracted"))
+		Expect(errors.Is(err, compress.ErrorRatioExceeded)).To(BeTrue())
+		err = New(Params{}).Decompress(outputPath, filepath.Join(tempPath, "extracted"))
+		Expect(errors.Is(err, compress.ErrorRatioExceeded)).To(BeTrue())
+
+		z := New(Params{Limits: compress.Limits{Unlimited: true}})
+		Expect(z.Decompress(outputPath, filepath.Join(tempPath, "extracted"))).To(Succeed())
+	})
 })
 
 const testDir = "test"
 
 var (
 	testFiles []string
+	tempPath  string
 	testPath  string
 
-	sourceData       [][]byte
-	decompressedData [][]byte
+	sourceData map[string][]byte
 )
 
-func removeTestFiles() error { return os.RemoveAll(testPath) }
-
-func readData(into *[][]byte) error {
-	*into = [][]byte{}
-	for _, value := range testFiles {
-		p := filepath.Join(testPath, value)
-		isDir, err := utils.IsDir(p)
-		if err != nil {
-			return fmt.Errorf("Ошибка IsDir() файла %s: %v", p, err)
-		}
-		if isDir {
-			continue
-		}
-		fileData, err := ioutil.ReadFile(p)
-		if err != nil {
-			return fmt.Errorf("Ошибка при чтении файла %s: %v", p, err)
-		}
-		*into = append(*into, fileData)
-	}
-	return nil
-}
+func removeTestFiles() error { return os.RemoveAll(tempPath) }
 
 func createTestFiles() {
-	testFiles = []string{}
+	var err error
+	tempPath, err = ioutil.TempDir("", "zip")
+	Expect(err).NotTo(HaveOccurred())
+	testPath = filepath.Join(tempPath, testDir)
+	Expect(os.MkdirAll(testPath, 0777)).To(Succeed())
+
+	testFiles, sourceData = []string{}, map[string][]byte{}
 
 	file1Name := "file1"
-	file1Path := filepath.Join(testPath, file1Name)
-	Expect(ioutil.WriteFile(file1Path, []byte("file1data"), 0660)).To(Succeed())
+	sourceData[file1Name] = []byte("file1data")
+	Expect(ioutil.WriteFile(filepath.Join(testPath, file1Name), sourceData[file1Name], 0660)).To(Succeed())
 	testFiles = append(testFiles, file1Name)
 
 	dir1Name := "dir1"
-	dir1Path := filepath.Join(testPath, dir1Name)
-	Expect(os.Mkdir(dir1Path, 0777)).To(Succeed())
+	Expect(os.Mkdir(filepath.Join(testPath, dir1Name), 0777)).To(Succeed())
 	testFiles = append(testFiles, dir1Name)
 
-	file2Name := "file2"
-	file2Path := filepath.Join(testPath, dir1Name, file2Name)
-	Expect(ioutil.WriteFile(file2Path, []byte("file2data"), 0660)).To(Succeed())
-	testFiles = append(testFiles, filepath.Join(dir1Name, file2Name))
-
-	Expect(readData(&sourceData)).To(Succeed())
+	file2Name := filepath.Join(dir1Name, "file2")
+	sourceData[file2Name] = []byte("file2data")
+	Expect(ioutil.WriteFile(filepath.Join(testPath, file2Name), sourceData[file2Name], 0660)).To(Succeed())
+	testFiles = append(testFiles, file2Name)
 }
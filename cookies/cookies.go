@@ -2,6 +2,8 @@ package cookies
 
 import (
 	"net/http"
+	"strings"
+	"time"
 )
 
 // HttpCookies is a slice of pointers to http.Cookie objects
@@ -14,6 +16,25 @@ func New(cookie string) HttpCookies {
 	return (&http.Request{Header: header}).Cookies()
 }
 
+// NewSetCookie parses Set-Cookie header values and returns cookies with all their attributes
+func NewSetCookie(setCookie ...string) HttpCookies {
+	header := http.Header{}
+	for _, value := range setCookie {
+		header.Add("Set-Cookie", value)
+	}
+	return NewFromHeader(header)
+}
+
+// NewFromHeader returns cookies from Cookie and Set-Cookie headers of header.
+// Cookies from Cookie headers go first and have names and values only
+func NewFromHeader(header http.Header) HttpCookies {
+	cookies := HttpCookies((&http.Request{Header: header}).Cookies())
+	return append(cookies, (&http.Response{Header: header}).Cookies()...)
+}
+
+// NewFromResponse returns cookies set by Set-Cookie headers of resp
+func NewFromResponse(resp *http.Response) HttpCookies { return resp.Cookies() }
+
 // Get returns a cookie with Name equals to key
 func (cookies HttpCookies) Get(key string) *http.Cookie {
 	for _, cookie := range cookies {
@@ -32,3 +53,78 @@ func (cookies HttpCookies) GetValue(key string) string {
 	}
 	return cookie.Value
 }
+
+// String returns cookie names and values as a Cookie header value, e.g. "token=abc; lang=ru".
+// Cookies with invalid names are skipped
+func (cookies HttpCookies) String() string {
+	values := make([]string, 0, len(cookies))
+	for _, cookie := range cookies {
+		if value := (&http.Cookie{Name: cookie.Name, Value: cookie.Value}).String(); value != "" {
+			values = append(values, value)
+		}
+	}
+	return strings.Join(values, "; ")
+}
+
+// SetCookieValues returns cookies with their attributes as Set-Cookie header values.
+// Cookies with invalid names are skipped
+func (cookies HttpCookies) SetCookieValues() []string {
+	values := make([]string, 0, len(cookies))
+	for _, cookie := range cookies {
+		if value := cookie.String(); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// Filter returns cookies fn returns true for
+func (cookies HttpCookies) Filter(fn func(cookie *http.Cookie) bool) HttpCookies {
+	result := HttpCookies{}
+	for _, cookie := range cookies {
+		if fn(cookie) {
+			result = append(result, cookie)
+		}
+	}
+	return result
+}
+
+// Expired returns cookies expired at t, see IsExpired
+func (cookies HttpCookies) Expired(t time.Time) HttpCookies {
+	return cookies.Filter(func(cookie *http.Cookie) bool { return IsExpired(cookie, t) })
+}
+
+// IsExpired returns true if cookie has negative MaxAge or Expires not after t.
+// Positive MaxAge is counted from the moment the cookie was received, which is unknown,
+// so such cookies are not expired
+func IsExpired(cookie *http.Cookie, t time.Time) bool {
+	if cookie.MaxAge != 0 {
+		return cookie.MaxAge < 0
+	}
+	return !cookie.Expires.IsZero() && !cookie.Expires.After(t)
+}
+
+// Merge returns cookies with others added. A cookie replaces an earlier one with the same name,
+// domain and path in its place, just like a Set-Cookie header replaces a stored cookie.
+// Domains are compared case insensitively ignoring a leading dot. Neither cookies nor others are changed
+func (cookies HttpCookies) Merge(others ...HttpCookies) HttpCookies {
+	result, index := HttpCookies{}, map[string]int{}
+	add := func(cookie *http.Cookie) {
+		key := cookie.Name + ";" + strings.ToLower(strings.TrimPrefix(cookie.Domain, ".")) + ";" + cookie.Path
+		if i, ok := index[key]; ok {
+			result[i] = cookie
+			return
+		}
+		index[key] = len(result)
+		result = append(result, cookie)
+	}
+	for _, cookie := range cookies {
+		add(cookie)
+	}
+	for _, other := range others {
+		for _, cookie := range other {
+			add(cookie)
+		}
+	}
+	return result
+}
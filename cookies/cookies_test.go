@@ -2,6 +2,8 @@ package cookies
 
 import (
 	"fmt"
+	"net/http"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -63,4 +65,71 @@ var _ = Describe("Testing with Ginkgo", func() {
 			Expect(cookies.GetValue(key)).To(Equal(expectedValue))
 		}
 	})
+
+	It("checks NewSetCookie", func() {
+		cookies := NewSetCookie(
+			"token=abc; Path=/app; Domain=.example.com; Expires=Wed, 01 Jan 2100 00:00:00 GMT; Secure; HttpOnly; SameSite=Strict",
+			"lang=ru; Max-Age=3600",
+			"bad name=x",
+		)
+		Expect(cookies).To(HaveLen(2))
+		token := cookies.Get("token")
+		Expect(token.Value).To(Equal("abc"))
+		Expect(token.Path).To(Equal("/app"))
+		Expect(token.Domain).To(Equal(".example.com"))
+		Expect(token.Expires.Equal(time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC))).To(BeTrue())
+		Expect(token.Secure).To(BeTrue())
+		Expect(token.HttpOnly).To(BeTrue())
+		Expect(token.SameSite).To(Equal(http.SameSiteStrictMode))
+		Expect(cookies.Get("lang").MaxAge).To(Equal(3600))
+	})
+
+	It("checks NewFromHeader and NewFromResponse", func() {
+		header := http.Header{}
+		header.Add("Cookie", "a=1; b=2")
+		header.Add("Set-Cookie", "c=3; Path=/")
+		cookies := NewFromHeader(header)
+		Expect(cookies.String()).To(Equal("a=1; b=2; c=3"))
+		Expect(cookies.Get("c").Path).To(Equal("/"))
+
+		cookies = NewFromResponse(&http.Response{Header: header})
+		Expect(cookies).To(HaveLen(1))
+		Expect(cookies.Get("c").Value).To(Equal("3"))
+	})
+
+	It("checks String and SetCookieValues", func() {
+		cookies := HttpCookies{
+			{Name: "token", Value: "abc", Path: "/", MaxAge: 60, HttpOnly: true},
+			{Name: "bad name", Value: "x"},
+			{Name: "quoted", Value: "a b"},
+		}
+		Expect(cookies.String()).To(Equal(`token=abc; quoted="a b"`))
+		Expect(cookies.SetCookieValues()).To(Equal([]string{"token=abc; Path=/; Max-Age=60; HttpOnly", `quoted="a b"`}))
+		Expect(NewSetCookie(cookies.SetCookieValues()...).Get("token").MaxAge).To(Equal(60))
+		Expect(New(cookies.String()).GetValue("quoted")).To(Equal("a b"))
+	})
+
+	It("checks Filter, Expired and Merge", func() {
+		now := time.Now()
+		cookies := HttpCookies{
+			{Name: "session", Value: "1"},
+			{Name: "deleted", Value: "2", MaxAge: -1},
+			{Name: "past", Value: "3", Expires: now.Add(-time.Minute)},
+			{Name: "future", Value: "4", Expires: now.Add(time.Minute)},
+			{Name: "maxage", Value: "5", MaxAge: 60, Expires: now.Add(-time.Minute)},
+		}
+		Expect(cookies.Expired(now).String()).To(Equal("deleted=2; past=3"))
+		Expect(cookies.Filter(func(c *http.Cookie) bool { return !IsExpired(c, now) }).String()).
+			To(Equal("session=1; future=4; maxage=5"))
+
+		merged := HttpCookies{
+			{Name: "a", Value: "1", Domain: "example.com", Path: "/"},
+			{Name: "a", Value: "2", Domain: "example.com", Path: "/app"},
+			{Name: "b", Value: "3"},
+		}.Merge(
+			HttpCookies{{Name: "a", Value: "replaced", Domain: ".Example.com", Path: "/"}},
+			HttpCookies{{Name: "c", Value: "4"}, {Name: "b", Value: "5"}},
+		)
+		Expect(merged.String()).To(Equal("a=replaced; a=2; b=5; c=4"))
+	})
 })
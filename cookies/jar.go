@@ -0,0 +1,533 @@
+package cookies
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// errors
+var (
+	ErrorUnknownFormat = errors.New("unknown cookie file format")
+	ErrorMalformedLine = errors.New("malformed cookies.txt line")
+	ErrorNoDomain      = errors.New("cookie has no domain")
+)
+
+// Format is a cookie file format
+type Format int
+
+// cookie file formats
+const (
+	FormatJSON     Format = iota // JSON array of cookies
+	FormatNetscape               // Netscape cookies.txt as curl and wget read and write it
+)
+
+// netscapeHeader is the first line of a Netscape cookies.txt file
+const netscapeHeader = "# Netscape HTTP Cookie File"
+
+// netscapeHttpOnly prefixes domains of HttpOnly cookies in Netscape cookies.txt
+const netscapeHttpOnly = "#HttpOnly_"
+
+// JarParams is a set of cookie jar parameters
+type JarParams struct {
+	// PublicSuffixList prevents setting cookies for public suffixes like "co.uk".
+	// If nil, only cookies for single label domains like "com" are refused
+	PublicSuffixList cookiejar.PublicSuffixList
+}
+
+// Jar is an http.CookieJar implementation following RFC 6265 domain, path and expiry rules
+// which can save its cookies to and load them from JSON or Netscape cookies.txt files.
+// Jar methods are safe for concurrent use
+type Jar struct {
+	params  JarParams
+	mu      sync.Mutex
+	entries map[string]entry
+	seq     uint64 // creation order of entries
+}
+
+var _ http.CookieJar = &Jar{}
+
+// entry is a stored cookie
+type entry struct {
+	Name, Value string
+	Domain      string // without a leading dot
+	Path        string
+	Expires     time.Time // zero for session cookies
+	Secure      bool
+	HttpOnly    bool
+	HostOnly    bool // the cookie is sent to Domain only, not to its subdomains
+	SameSite    http.SameSite
+	seq         uint64 // creation order
+}
+
+// jsonEntry is an entry as it is saved in JSON format
+type jsonEntry struct {
+	Name     string        `json:"name"`
+	Value    string        `json:"value"`
+	Domain   string        `json:"domain"`
+	Path     string        `json:"path"`
+	Expires  *time.Time    `json:"expires,omitempty"`
+	Secure   bool          `json:"secure,omitempty"`
+	HttpOnly bool          `json:"http_only,omitempty"`
+	HostOnly bool          `json:"host_only,omitempty"`
+	SameSite http.SameSite `json:"same_site,omitempty"`
+}
+
+// key returns a key identifying the entry in a jar
+func (e entry) key() string { return e.Domain + ";" + e.Path + ";" + e.Name }
+
+// expired returns true if the entry is not a session one and has expired at t
+func (e entry) expired(t time.Time) bool { return !e.Expires.IsZero() && !e.Expires.After(t) }
+
+// cookie returns the entry as http.Cookie with all attributes set.
+// Domain has a leading dot unless the cookie is host-only
+func (e entry) cookie() *http.Cookie {
+	c := &http.Cookie{
+		Name:     e.Name,
+		Value:    e.Value,
+		Domain:   e.Domain,
+		Path:     e.Path,
+		Expires:  e.Expires,
+		Secure:   e.Secure,
+		HttpOnly: e.HttpOnly,
+		SameSite: e.SameSite,
+	}
+	if !e.HostOnly {
+		c.Domain = "." + c.Domain
+	}
+	return c
+}
+
+// NewJar returns a new empty cookie jar with given params
+func NewJar(params JarParams) *Jar { return &Jar{params: params, entries: map[string]entry{}} }
+
+// SetCookies implements http.CookieJar. Cookies for non-HTTP(S) u or with a domain u does not belong to
+// are ignored, cookies with negative MaxAge or expired ones delete stored cookies with the same name,
+// domain and path
+func (j *Jar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return
+	}
+	host, ok := canonicalHost(u)
+	if !ok {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	now := time.Now()
+	for _, c := range cookies {
+		e, ok := j.newEntry(c, host, defaultPath(u.Path), now)
+		if !ok {
+			continue
+		}
+		j.store(e, now)
+	}
+}
+
+// Cookies implements http.CookieJar. It returns cookies to send to u with names and values only,
+// longer paths first, just like a browser orders them
+func (j *Jar) Cookies(u *url.URL) []*http.Cookie {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil
+	}
+	host, ok := canonicalHost(u)
+	if !ok {
+		return nil
+	}
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	now, selected := time.Now(), []entry{}
+	for key, e := range j.entries {
+		if e.expired(now) {
+			delete(j.entries, key)
+			continue
+		}
+		if e.Secure && u.Scheme != "https" {
+			continue
+		}
+		if !e.domainMatch(host) || !pathMatch(e.Path, path) {
+			continue
+		}
+		selected = append(selected, e)
+	}
+	sortEntries(selected)
+
+	result := make([]*http.Cookie, 0, len(selected))
+	for _, e := range selected {
+		result = append(result, &http.Cookie{Name: e.Name, Value: e.Value})
+	}
+	return result
+}
+
+// HttpCookies returns all stored unexpired cookies with their Domain, Path, Expires, Secure,
+// HttpOnly and SameSite attributes. Domains of cookies sent to subdomains have a leading dot
+func (j *Jar) HttpCookies() HttpCookies {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	result := HttpCookies{}
+	for _, e := range j.sorted(time.Now()) {
+		result = append(result, e.cookie())
+	}
+	return result
+}
+
+// AddHttpCookies stores cookies into the jar as they are, without checking them against a request URL.
+// Every cookie should have a Domain. Just like HttpCookies returns them, a cookie with a leading dot
+// in Domain is sent to subdomains as well, otherwise it is host-only. Path defaults to "/".
+// ErrorNoDomain is returned if a cookie has no domain, no cookies are stored then
+func (j *Jar) AddHttpCookies(cookies HttpCookies) error {
+	for _, c := range cookies {
+		if strings.TrimPrefix(c.Domain, ".") == "" {
+			return fmt.Errorf("%w: %s", ErrorNoDomain, c.Name)
+		}
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	now := time.Now()
+	for _, c := range cookies {
+		hostOnly := *c
+		hostOnly.Domain = ""
+		e, _ := j.newEntry(&hostOnly, strings.ToLower(strings.TrimPrefix(c.Domain, ".")), "/", now)
+		e.HostOnly = !strings.HasPrefix(c.Domain, ".")
+		j.store(e, now)
+	}
+	return nil
+}
+
+// newEntry returns an entry for cookie c set by host with a default path.
+// False is returned if c may not be set by host
+func (j *Jar) newEntry(c *http.Cookie, host string, path string, now time.Time) (entry, bool) {
+	e := entry{
+		Name:     c.Name,
+		Value:    c.Value,
+		Domain:   host,
+		Path:     c.Path,
+		Secure:   c.Secure,
+		HttpOnly: c.HttpOnly,
+		SameSite: c.SameSite,
+		HostOnly: true,
+	}
+	if e.Path == "" || e.Path[0] != '/' {
+		e.Path = path
+	}
+
+	switch {
+	case c.MaxAge < 0:
+		e.Expires = time.Unix(1, 0)
+	case c.MaxAge > 0:
+		e.Expires = now.Add(time.Duration(c.MaxAge) * time.Second)
+	case !c.Expires.IsZero():
+		e.Expires = c.Expires
+	}
+
+	if c.Domain != "" {
+		domain, ok := j.cookieDomain(host, c.Domain)
+		if !ok {
+			return e, false
+		}
+		e.Domain, e.HostOnly = domain, domain == host && net.ParseIP(host) != nil
+	}
+	return e, true
+}
+
+// cookieDomain returns a domain the Domain attribute value domain sets a cookie for, if host may set it
+func (j *Jar) cookieDomain(host string, domain string) (string, bool) {
+	domain = strings.ToLower(strings.TrimPrefix(domain, "."))
+	if domain == "" {
+		return "", false
+	}
+	if net.ParseIP(host) != nil {
+		// IP addresses may only set host-only cookies
+		return host, domain == host
+	}
+	if host != domain && !strings.HasSuffix(host, "."+domain) {
+		return "", false
+	}
+	if host == domain {
+		return domain, true
+	}
+	if j.params.PublicSuffixList != nil {
+		if j.params.PublicSuffixList.PublicSuffix(domain) == domain {
+			return "", false
+		}
+	} else if !strings.Contains(domain, ".") {
+		return "", false
+	}
+	return domain, true
+}
+
+// store stores e replacing an entry with the same key, an expired e deletes it
+func (j *Jar) store(e entry, now time.Time) {
+	key := e.key()
+	if e.expired(now) {
+		delete(j.entries, key)
+		return
+	}
+	if old, ok := j.entries[key]; ok {
+		e.seq = old.seq
+	} else {
+		j.seq++
+		e.seq = j.seq
+	}
+	j.entries[key] = e
+}
+
+// sorted returns unexpired entries in order they should be sent or saved
+func (j *Jar) sorted(now time.Time) []entry {
+	result := make([]entry, 0, len(j.entries))
+	for _, e := range j.entries {
+		if !e.expired(now) {
+			result = append(result, e)
+		}
+	}
+	sortEntries(result)
+	return result
+}
+
+// sortEntries sorts entries by path length descending and then by creation order
+func sortEntries(entries []entry) {
+	sort.Slice(entries, func(i, k int) bool {
+		if len(entries[i].Path) != len(entries[k].Path) {
+			return len(entries[i].Path) > len(entries[k].Path)
+		}
+		return entries[i].seq < entries[k].seq
+	})
+}
+
+// domainMatch returns true if the entry should be sent to host
+func (e entry) domainMatch(host string) bool {
+	if e.Domain == host {
+		return true
+	}
+	return !e.HostOnly && strings.HasSuffix(host, "."+e.Domain) && net.ParseIP(host) == nil
+}
+
+// pathMatch returns true if a cookie with path cookiePath should be sent for requestPath as RFC 6265 5.1.4 says
+func pathMatch(cookiePath string, requestPath string) bool {
+	if !strings.HasPrefix(requestPath, cookiePath) {
+		return false
+	}
+	return len(requestPath) == len(cookiePath) || strings.HasSuffix(cookiePath, "/") ||
+		requestPath[len(cookiePath)] == '/'
+}
+
+// defaultPath returns a default cookie path for a request path as RFC 6265 5.1.4 says
+func defaultPath(path string) string {
+	i := strings.LastIndex(path, "/")
+	if i <= 0 {
+		return "/"
+	}
+	return path[:i]
+}
+
+// canonicalHost returns u host in lower case without a port and a trailing dot
+func canonicalHost(u *url.URL) (string, bool) {
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	return host, host != ""
+}
+
+// Save writes all stored unexpired cookies including session ones into w in given format
+func (j *Jar) Save(w io.Writer, format Format) error {
+	j.mu.Lock()
+	entries := j.sorted(time.Now())
+	j.mu.Unlock()
+
+	switch format {
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(toJSON(entries))
+	case FormatNetscape:
+		return writeNetscape(w, entries)
+	}
+	return ErrorUnknownFormat
+}
+
+// Load reads cookies in given format from r adding them to the jar.
+// Expired cookies are skipped, stored cookies with the same name, domain and path are replaced.
+// Nothing is added if any of the cookies is invalid
+func (j *Jar) Load(r io.Reader, format Format) error {
+	var entries []entry
+	var err error
+	switch format {
+	case FormatJSON:
+		var jsonEntries []jsonEntry
+		err = json.NewDecoder(r).Decode(&jsonEntries)
+		entries = fromJSON(jsonEntries)
+	case FormatNetscape:
+		entries, err = readNetscape(r)
+	default:
+		err = ErrorUnknownFormat
+	}
+	if err != nil {
+		return err
+	}
+
+	for i, e := range entries {
+		e.Domain = strings.ToLower(strings.TrimPrefix(e.Domain, "."))
+		if e.Domain == "" {
+			return fmt.Errorf("%w: %s", ErrorNoDomain, e.Name)
+		}
+		if e.Path == "" {
+			e.Path = "/"
+		}
+		entries[i] = e
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	now := time.Now()
+	for _, e := range entries {
+		j.store(e, now)
+	}
+	return nil
+}
+
+// SaveFile writes all stored unexpired cookies into file fileName in given format
+func (j *Jar) SaveFile(fileName string, format Format) error {
+	f, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	if err := j.Save(f, format); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// LoadFile reads cookies in given format from file fileName adding them to the jar
+func (j *Jar) LoadFile(fileName string, format Format) error {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return j.Load(f, format)
+}
+
+// toJSON converts entries to be saved in JSON format
+func toJSON(entries []entry) []jsonEntry {
+	result := make([]jsonEntry, 0, len(entries))
+	for _, e := range entries {
+		je := jsonEntry{
+			Name:     e.Name,
+			Value:    e.Value,
+			Domain:   e.Domain,
+			Path:     e.Path,
+			Secure:   e.Secure,
+			HttpOnly: e.HttpOnly,
+			HostOnly: e.HostOnly,
+			SameSite: e.SameSite,
+		}
+		if !e.Expires.IsZero() {
+			expires := e.Expires
+			je.Expires = &expires
+		}
+		result = append(result, je)
+	}
+	return result
+}
+
+// fromJSON converts entries loaded in JSON format
+func fromJSON(jsonEntries []jsonEntry) []entry {
+	result := make([]entry, 0, len(jsonEntries))
+	for _, je := range jsonEntries {
+		e := entry{
+			Name:     je.Name,
+			Value:    je.Value,
+			Domain:   je.Domain,
+			Path:     je.Path,
+			Secure:   je.Secure,
+			HttpOnly: je.HttpOnly,
+			HostOnly: je.HostOnly,
+			SameSite: je.SameSite,
+		}
+		if je.Expires != nil {
+			e.Expires = *je.Expires
+		}
+		result = append(result, e)
+	}
+	return result
+}
+
+// writeNetscape writes entries into w in Netscape cookies.txt format
+func writeNetscape(w io.Writer, entries []entry) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, netscapeHeader)
+	fmt.Fprintln(bw)
+	for _, e := range entries {
+		domain, includeSubdomains := e.Domain, "FALSE"
+		if !e.HostOnly {
+			domain, includeSubdomains = "."+domain, "TRUE"
+		}
+		if e.HttpOnly {
+			domain = netscapeHttpOnly + domain
+		}
+		var expires int64
+		if !e.Expires.IsZero() {
+			expires = e.Expires.Unix()
+		}
+		fmt.Fprintf(bw, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			domain, includeSubdomains, e.Path, strings.ToUpper(strconv.FormatBool(e.Secure)), expires, e.Name, e.Value)
+	}
+	return bw.Flush()
+}
+
+// readNetscape reads entries in Netscape cookies.txt format from r
+func readNetscape(r io.Reader) ([]entry, error) {
+	entries := []entry{}
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		httpOnly := strings.HasPrefix(line, netscapeHttpOnly)
+		if httpOnly {
+			line = strings.TrimPrefix(line, netscapeHttpOnly)
+		}
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) != 7 {
+			return nil, fmt.Errorf("%w %d: %d fields instead of 7", ErrorMalformedLine, n, len(fields))
+		}
+		expires, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w %d: %v", ErrorMalformedLine, n, err)
+		}
+		e := entry{
+			Domain:   fields[0],
+			HostOnly: !strings.EqualFold(fields[1], "TRUE"),
+			Path:     fields[2],
+			Secure:   strings.EqualFold(fields[3], "TRUE"),
+			Name:     fields[5],
+			Value:    fields[6],
+			HttpOnly: httpOnly,
+		}
+		if expires > 0 {
+			e.Expires = time.Unix(expires, 0)
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
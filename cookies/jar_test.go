@@ -0,0 +1,231 @@
+package cookies
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// publicSuffixes is a cookiejar.PublicSuffixList for tests
+type publicSuffixes map[string]bool
+
+func (l publicSuffixes) PublicSuffix(domain string) string {
+	for d := domain; ; {
+		if l[d] {
+			return d
+		}
+		i := strings.Index(d, ".")
+		if i < 0 {
+			return d
+		}
+		d = d[i+1:]
+	}
+}
+
+func (l publicSuffixes) String() string { return "test list" }
+
+var _ = Describe("Jar", func() {
+	mustParse := func(s string) *url.URL {
+		u, err := url.Parse(s)
+		Expect(err).NotTo(HaveOccurred())
+		return u
+	}
+	names := func(cookies []*http.Cookie) string {
+		result := []string{}
+		for _, c := range cookies {
+			result = append(result, c.Name+"="+c.Value)
+		}
+		return strings.Join(result, "; ")
+	}
+
+	It("checks domain, path and secure matching", func() {
+		jar := NewJar(JarParams{})
+		jar.SetCookies(mustParse("https://www.example.com/shop/cart"), []*http.Cookie{
+			{Name: "host", Value: "1"},
+			{Name: "domain", Value: "2", Domain: ".example.com"},
+			{Name: "root", Value: "3", Path: "/"},
+			{Name: "secure", Value: "4", Path: "/", Secure: true},
+			{Name: "foreign", Value: "5", Domain: "other.com"},
+			{Name: "tld", Value: "6", Domain: "com"},
+		})
+
+		testCases := map[string]string{
+			"https://www.example.com/shop/cart": "host=1; domain=2; root=3; secure=4",
+			"https://www.example.com/shop":      "host=1; domain=2; root=3; secure=4",
+			"http://www.example.com/shopping":   "root=3",
+			"https://example.com/shop/":         "domain=2",
+			"https://a.www.example.com/shop":    "domain=2",
+			"https://other.com/":                "",
+			"ftp://www.example.com/shop":        "",
+		}
+		for u, expected := range testCases {
+			By(fmt.Sprintf("testing case %s", u))
+			Expect(names(jar.Cookies(mustParse(u)))).To(Equal(expected))
+		}
+	})
+
+	It("checks expiry and deletion", func() {
+		jar := NewJar(JarParams{})
+		u := mustParse("http://example.com/")
+		jar.SetCookies(u, []*http.Cookie{
+			{Name: "session", Value: "1"},
+			{Name: "maxage", Value: "2", MaxAge: 3600},
+			{Name: "expires", Value: "3", Expires: time.Now().Add(time.Hour)},
+			{Name: "expired", Value: "4", Expires: time.Now().Add(-time.Hour)},
+		})
+		Expect(names(jar.Cookies(u))).To(Equal("session=1; maxage=2; expires=3"))
+
+		jar.SetCookies(u, []*http.Cookie{
+			{Name: "maxage", MaxAge: -1},
+			{Name: "expires", Expires: time.Unix(1, 0)},
+			{Name: "session", Value: "updated"},
+		})
+		Expect(names(jar.Cookies(u))).To(Equal("session=updated"))
+	})
+
+	It("checks public suffixes and IP hosts", func() {
+		jar := NewJar(JarParams{PublicSuffixList: publicSuffixes{"co.uk": true}})
+		jar.SetCookies(mustParse("http://shop.example.co.uk/"), []*http.Cookie{
+			{Name: "suffix", Value: "1", Domain: "co.uk"},
+			{Name: "site", Value: "2", Domain: "example.co.uk"},
+		})
+		Expect(names(jar.Cookies(mustParse("http://other.co.uk/")))).To(BeEmpty())
+		Expect(names(jar.Cookies(mustParse("http://example.co.uk/")))).To(Equal("site=2"))
+
+		jar.SetCookies(mustParse("http://127.0.0.1:8080/"), []*http.Cookie{
+			{Name: "ip", Value: "1", Domain: "127.0.0.1"},
+			{Name: "wrong", Value: "2", Domain: "0.0.1"},
+		})
+		Expect(names(jar.Cookies(mustParse("http://127.0.0.1/")))).To(Equal("ip=1"))
+	})
+
+	It("works as http.Client jar", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/login" {
+				http.SetCookie(w, &http.Cookie{Name: "token", Value: "secret", Path: "/", MaxAge: 60})
+				return
+			}
+			cookie, err := r.Cookie("token")
+			if err != nil {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(w, cookie.Value)
+		}))
+		defer server.Close()
+
+		client := &http.Client{Jar: NewJar(JarParams{})}
+		resp, err := client.Get(server.URL + "/login")
+		Expect(err).NotTo(HaveOccurred())
+		resp.Body.Close()
+		resp, err = client.Get(server.URL + "/data")
+		Expect(err).NotTo(HaveOccurred())
+		defer resp.Body.Close()
+		Expect(ioutil.ReadAll(resp.Body)).To(Equal([]byte("secret")))
+	})
+
+	It("checks HttpCookies and AddHttpCookies", func() {
+		jar := NewJar(JarParams{})
+		expires := time.Now().Add(time.Hour).Truncate(time.Second)
+		jar.SetCookies(mustParse("https://example.com/a/b"), []*http.Cookie{
+			{Name: "host", Value: "1", HttpOnly: true},
+			{Name: "domain", Value: "2", Domain: "example.com", Path: "/", Expires: expires, Secure: true},
+		})
+		cookies := jar.HttpCookies()
+		Expect(cookies).To(HaveLen(2))
+		Expect(*cookies.Get("host")).To(Equal(http.Cookie{Name: "host", Value: "1", Domain: "example.com", Path: "/a", HttpOnly: true}))
+		Expect(cookies.Get("domain").Domain).To(Equal(".example.com"))
+		Expect(cookies.Get("domain").Expires.Equal(expires)).To(BeTrue())
+
+		restored := NewJar(JarParams{})
+		Expect(restored.AddHttpCookies(cookies)).To(Succeed())
+		Expect(restored.HttpCookies()).To(Equal(cookies))
+		Expect(names(restored.Cookies(mustParse("https://sub.example.com/a")))).To(Equal("domain=2"))
+
+		err := restored.AddHttpCookies(HttpCookies{{Name: "x", Value: "y"}})
+		Expect(errors.Is(err, ErrorNoDomain)).To(BeTrue())
+	})
+
+	It("checks Save and Load", func() {
+		jar := NewJar(JarParams{})
+		jar.SetCookies(mustParse("https://example.com/"), []*http.Cookie{
+			{Name: "session", Value: "1", HttpOnly: true},
+			{Name: "persistent", Value: "a=b", Domain: "example.com", MaxAge: 3600, Secure: true, SameSite: http.SameSiteLaxMode},
+		})
+		tempPath, err := ioutil.TempDir("", "cookies")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(tempPath)
+
+		for i, format := range []Format{FormatJSON, FormatNetscape} {
+			By(fmt.Sprintf("testing case %d", i))
+			fileName := filepath.Join(tempPath, fmt.Sprintf("cookies%d", i))
+			Expect(jar.SaveFile(fileName, format)).To(Succeed())
+			loaded := NewJar(JarParams{})
+			Expect(loaded.LoadFile(fileName, format)).To(Succeed())
+
+			expected := jar.HttpCookies()
+			if format == FormatNetscape {
+				// cookies.txt has no SameSite and keeps expiry in seconds
+				expected[1].SameSite, expected[1].Expires = 0, expected[1].Expires.Truncate(time.Second)
+			}
+			actual := loaded.HttpCookies()
+			Expect(actual).To(HaveLen(2))
+			for k := range expected {
+				Expect(actual[k].Expires.Equal(expected[k].Expires)).To(BeTrue())
+				actual[k].Expires, expected[k].Expires = time.Time{}, time.Time{}
+			}
+			Expect(actual).To(Equal(expected))
+		}
+		Expect(jar.Save(&bytes.Buffer{}, Format(10))).To(Equal(ErrorUnknownFormat))
+	})
+
+	It("checks Netscape cookies.txt reading", func() {
+		data := strings.Join([]string{
+			netscapeHeader,
+			"# comment",
+			"",
+			".example.com\tTRUE\t/\tFALSE\t0\tsession\t1",
+			"#HttpOnly_example.com\tFALSE\t/app\tTRUE\t4102444800\ttoken\tabc",
+			"example.com\tFALSE\t/\tFALSE\t1\texpired\tx",
+		}, "\n")
+		jar := NewJar(JarParams{})
+		Expect(jar.Load(strings.NewReader(data), FormatNetscape)).To(Succeed())
+		cookies := jar.HttpCookies()
+		Expect(cookies).To(HaveLen(2))
+		Expect(*cookies.Get("token")).To(Equal(http.Cookie{
+			Name: "token", Value: "abc", Domain: "example.com", Path: "/app",
+			Expires: time.Unix(4102444800, 0), Secure: true, HttpOnly: true,
+		}))
+		Expect(cookies.Get("session").Domain).To(Equal(".example.com"))
+		Expect(cookies.Get("session").Expires.IsZero()).To(BeTrue())
+
+		err := jar.Load(strings.NewReader("example.com\tFALSE\t/\n"), FormatNetscape)
+		Expect(errors.Is(err, ErrorMalformedLine)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring("line 1"))
+	})
+
+	It("loads nothing if any cookie is invalid", func() {
+		jar := NewJar(JarParams{})
+		data := `[{"name": "valid", "value": "1", "domain": "example.com"}, {"name": "invalid", "value": "2"}]`
+		err := jar.Load(strings.NewReader(data), FormatJSON)
+		Expect(errors.Is(err, ErrorNoDomain)).To(BeTrue())
+		Expect(jar.HttpCookies()).To(BeEmpty())
+	})
+
+	It("ignores cookies set for non-HTTP URLs", func() {
+		jar := NewJar(JarParams{})
+		jar.SetCookies(mustParse("ftp://example.com/"), []*http.Cookie{{Name: "ftp", Value: "1", Domain: "example.com"}})
+		Expect(jar.HttpCookies()).To(BeEmpty())
+	})
+})
@@ -0,0 +1,181 @@
+package cookies
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// errors
+var (
+	ErrorNoKeys       = errors.New("no codec keys")
+	ErrorInvalidKey   = errors.New("invalid codec key")
+	ErrorInvalidValue = errors.New("invalid or tampered secure cookie value")
+	ErrorExpiredValue = errors.New("secure cookie value has expired")
+	ErrorValueTooLong = errors.New("encoded cookie value is too long")
+)
+
+const (
+	timestampSize       = 8    // size of a timestamp embedded into encoded values
+	maxEncodedValueSize = 4000 // encoded values longer than that may not fit into a cookie with its attributes
+
+	clockSkew = time.Minute // allowed clock difference, values encoded further in the future are refused if MaxAge is set
+)
+
+// Key is a pair of secret keys of a Codec
+type Key struct {
+	Hash  []byte // HMAC-SHA256 key, should be 32 or 64 random bytes
+	Block []byte // AES key of 16, 24 or 32 bytes, values are AES-GCM encrypted if set and only signed if nil
+}
+
+// CodecParams is a set of secure cookie codec parameters
+type CodecParams struct {
+	// Keys are used to decode values in order, the first one also encodes them. To rotate keys,
+	// put a new key first and keep older ones after it until values encoded with them expire
+	Keys   []Key
+	MaxAge time.Duration // values encoded earlier are refused with ErrorExpiredValue, no limit if zero
+}
+
+// Codec encodes cookie values signing them with HMAC-SHA256 and optionally encrypting them with AES-GCM.
+// Encoded values embed a timestamp and are bound to a cookie name, so they can not be moved to another cookie
+type Codec struct {
+	params CodecParams
+	keys   []codecKey
+	now    func() time.Time
+}
+
+// codecKey is a Key prepared for use
+type codecKey struct {
+	hash []byte
+	aead cipher.AEAD // nil if values are not encrypted
+}
+
+// NewCodec returns a new secure cookie codec with given params.
+// ErrorNoKeys or ErrorInvalidKey is returned if keys are missing or are of wrong size
+func NewCodec(params CodecParams) (*Codec, error) {
+	if len(params.Keys) == 0 {
+		return nil, ErrorNoKeys
+	}
+	c := &Codec{params: params, now: time.Now}
+	for _, key := range params.Keys {
+		if len(key.Hash) == 0 {
+			return nil, ErrorInvalidKey
+		}
+		ck := codecKey{hash: key.Hash}
+		if key.Block != nil {
+			block, err := aes.NewCipher(key.Block)
+			if err != nil {
+				return nil, ErrorInvalidKey
+			}
+			if ck.aead, err = cipher.NewGCM(block); err != nil {
+				return nil, err
+			}
+		}
+		c.keys = append(c.keys, ck)
+	}
+	return c, nil
+}
+
+// Encode returns value of a cookie with given name encoded with the first key as URL safe base64.
+// ErrorValueTooLong is returned if the result does not fit into a cookie
+func (c *Codec) Encode(name string, value string) (string, error) {
+	key := c.keys[0]
+	payload := make([]byte, timestampSize, timestampSize+len(value))
+	binary.BigEndian.PutUint64(payload, uint64(c.now().Unix()))
+	payload = append(payload, value...)
+
+	if key.aead != nil {
+		nonce := make([]byte, key.aead.NonceSize(), key.aead.NonceSize()+len(payload)+key.aead.Overhead())
+		if _, err := rand.Read(nonce); err != nil {
+			return "", err
+		}
+		payload = key.aead.Seal(nonce, nonce, payload, []byte(name))
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(append(payload, key.mac(name, payload)...))
+	if len(encoded) > maxEncodedValueSize {
+		return "", ErrorValueTooLong
+	}
+	return encoded, nil
+}
+
+// Decode returns value of a cookie with given name decoded with any of the keys.
+// ErrorInvalidValue is returned if encoded is malformed, tampered, was encoded for another name
+// or with an unknown key, ErrorExpiredValue is returned if it is older than MaxAge.
+// With MaxAge set, values encoded in the future beyond a clock skew are refused with ErrorInvalidValue
+func (c *Codec) Decode(name string, encoded string) (string, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || len(data) < sha256.Size {
+		return "", ErrorInvalidValue
+	}
+	payload, mac := data[:len(data)-sha256.Size], data[len(data)-sha256.Size:]
+
+	for _, key := range c.keys {
+		if !hmac.Equal(mac, key.mac(name, payload)) {
+			continue
+		}
+		if key.aead != nil {
+			nonceSize := key.aead.NonceSize()
+			if len(payload) < nonceSize {
+				return "", ErrorInvalidValue
+			}
+			if payload, err = key.aead.Open(nil, payload[:nonceSize], payload[nonceSize:], []byte(name)); err != nil {
+				return "", ErrorInvalidValue
+			}
+		}
+		if len(payload) < timestampSize {
+			return "", ErrorInvalidValue
+		}
+		created := time.Unix(int64(binary.BigEndian.Uint64(payload)), 0)
+		if c.params.MaxAge > 0 {
+			now := c.now()
+			if created.After(now.Add(clockSkew)) {
+				return "", ErrorInvalidValue
+			}
+			if now.Sub(created) > c.params.MaxAge {
+				return "", ErrorExpiredValue
+			}
+		}
+		return string(payload[timestampSize:]), nil
+	}
+	return "", ErrorInvalidValue
+}
+
+// mac returns HMAC-SHA256 of payload of a cookie with given name
+func (k codecKey) mac(name string, payload []byte) []byte {
+	h := hmac.New(sha256.New, k.hash)
+	h.Write([]byte(name))
+	h.Write([]byte{0})
+	h.Write(payload)
+	return h.Sum(nil)
+}
+
+// GetSecure returns a value of a cookie with Name equals to key decoded with codec.
+// http.ErrNoCookie is returned if there is no such cookie
+func (cookies HttpCookies) GetSecure(codec *Codec, key string) (string, error) {
+	cookie := cookies.Get(key)
+	if cookie == nil {
+		return "", http.ErrNoCookie
+	}
+	return codec.Decode(key, cookie.Value)
+}
+
+// SetSecure adds a Set-Cookie header to w with cookie value encoded with codec.
+// Other cookie attributes are kept as they are, cookie itself is not changed
+func SetSecure(w http.ResponseWriter, codec *Codec, cookie *http.Cookie) error {
+	value, err := codec.Encode(cookie.Name, cookie.Value)
+	if err != nil {
+		return err
+	}
+	encoded := *cookie
+	encoded.Value = value
+	http.SetCookie(w, &encoded)
+	return nil
+}
@@ -0,0 +1,130 @@
+package cookies
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Codec", func() {
+	hashKey, blockKey := bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{2}, 32)
+	newHashKey, newBlockKey := bytes.Repeat([]byte{3}, 32), bytes.Repeat([]byte{4}, 16)
+
+	newCodec := func(params CodecParams) *Codec {
+		codec, err := NewCodec(params)
+		Expect(err).NotTo(HaveOccurred())
+		return codec
+	}
+
+	It("checks NewCodec", func() {
+		testCases := []struct {
+			keys []Key
+			err  error
+		}{
+			{nil, ErrorNoKeys},
+			{[]Key{{}}, ErrorInvalidKey},
+			{[]Key{{Hash: hashKey, Block: []byte("short")}}, ErrorInvalidKey},
+			{[]Key{{Hash: hashKey}, {Hash: newHashKey, Block: newBlockKey}}, nil},
+		}
+		for i, tc := range testCases {
+			By(fmt.Sprintf("testing case %d", i))
+			_, err := NewCodec(CodecParams{Keys: tc.keys})
+			if tc.err == nil {
+				Expect(err).NotTo(HaveOccurred())
+				continue
+			}
+			Expect(err).To(Equal(tc.err))
+		}
+	})
+
+	It("checks Encode and Decode", func() {
+		for i, key := range []Key{{Hash: hashKey}, {Hash: hashKey, Block: blockKey}} {
+			By(fmt.Sprintf("testing case %d", i))
+			codec := newCodec(CodecParams{Keys: []Key{key}})
+			encoded, err := codec.Encode("session", "user=42; role=admin")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(encoded).NotTo(ContainSubstring(";"))
+			Expect(codec.Decode("session", encoded)).To(Equal("user=42; role=admin"))
+			Expect(strings.Contains(encoded, "admin")).To(BeFalse())
+
+			_, err = codec.Decode("other", encoded)
+			Expect(err).To(Equal(ErrorInvalidValue))
+			tampered := []byte(encoded)
+			tampered[len(tampered)/2] ^= 1
+			_, err = codec.Decode("session", string(tampered))
+			Expect(err).To(Equal(ErrorInvalidValue))
+			_, err = codec.Decode("session", "%%%")
+			Expect(err).To(Equal(ErrorInvalidValue))
+		}
+
+		By("testing encryption")
+		encrypted := newCodec(CodecParams{Keys: []Key{{Hash: hashKey, Block: blockKey}}})
+		first, err := encrypted.Encode("session", "value")
+		Expect(err).NotTo(HaveOccurred())
+		second, err := encrypted.Encode("session", "value")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(first).NotTo(Equal(second))
+
+		_, err = encrypted.Encode("big", strings.Repeat("x", 4000))
+		Expect(err).To(Equal(ErrorValueTooLong))
+	})
+
+	It("checks MaxAge", func() {
+		now := time.Now()
+		codec := newCodec(CodecParams{Keys: []Key{{Hash: hashKey}}, MaxAge: time.Hour})
+		codec.now = func() time.Time { return now }
+		encoded, err := codec.Encode("session", "value")
+		Expect(err).NotTo(HaveOccurred())
+
+		codec.now = func() time.Time { return now.Add(59 * time.Minute) }
+		Expect(codec.Decode("session", encoded)).To(Equal("value"))
+		codec.now = func() time.Time { return now.Add(61 * time.Minute) }
+		_, err = codec.Decode("session", encoded)
+		Expect(err).To(Equal(ErrorExpiredValue))
+
+		By("testing values encoded in the future")
+		codec.now = func() time.Time { return now.Add(-30 * time.Second) }
+		Expect(codec.Decode("session", encoded)).To(Equal("value"))
+		codec.now = func() time.Time { return now.Add(-2 * time.Minute) }
+		_, err = codec.Decode("session", encoded)
+		Expect(err).To(Equal(ErrorInvalidValue))
+	})
+
+	It("checks key rotation", func() {
+		old := newCodec(CodecParams{Keys: []Key{{Hash: hashKey, Block: blockKey}}})
+		encoded, err := old.Encode("session", "value")
+		Expect(err).NotTo(HaveOccurred())
+
+		rotated := newCodec(CodecParams{Keys: []Key{{Hash: newHashKey, Block: newBlockKey}, {Hash: hashKey, Block: blockKey}}})
+		Expect(rotated.Decode("session", encoded)).To(Equal("value"))
+		reencoded, err := rotated.Encode("session", "value")
+		Expect(err).NotTo(HaveOccurred())
+		_, err = old.Decode("session", reencoded)
+		Expect(err).To(Equal(ErrorInvalidValue))
+	})
+
+	It("checks SetSecure and GetSecure", func() {
+		codec := newCodec(CodecParams{Keys: []Key{{Hash: hashKey, Block: blockKey}}})
+		recorder := httptest.NewRecorder()
+		cookie := &http.Cookie{Name: "session", Value: "user=42", Path: "/", HttpOnly: true}
+		Expect(SetSecure(recorder, codec, cookie)).To(Succeed())
+		Expect(cookie.Value).To(Equal("user=42"))
+
+		cookies := NewFromResponse(recorder.Result())
+		Expect(cookies).To(HaveLen(1))
+		Expect(cookies.Get("session").HttpOnly).To(BeTrue())
+		Expect(cookies.Get("session").Value).NotTo(Equal("user=42"))
+		Expect(cookies.GetSecure(codec, "session")).To(Equal("user=42"))
+
+		_, err := cookies.GetSecure(codec, "missing")
+		Expect(err).To(Equal(http.ErrNoCookie))
+		_, err = New("session=forged").GetSecure(codec, "session")
+		Expect(err).To(Equal(ErrorInvalidValue))
+	})
+})
@@ -0,0 +1,276 @@
+package cookies
+
+import (
+	"encoding"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// errors
+var (
+	ErrorNotStructPointer = errors.New("bind target is not a pointer to a struct")
+	ErrorUnsupportedType  = errors.New("unsupported field type")
+)
+
+// ValueError describes a cookie value which can not be converted to a requested type
+type ValueError struct {
+	Name  string // cookie name
+	Value string // cookie value
+	Err   error  // conversion error
+}
+
+// Error implements error interface
+func (e *ValueError) Error() string { return fmt.Sprintf("cookie %s=%q: %v", e.Name, e.Value, e.Err) }
+
+// Unwrap returns the underlying error
+func (e *ValueError) Unwrap() error { return e.Err }
+
+// value returns a value of a cookie with Name equals to key or http.ErrNoCookie
+func (cookies HttpCookies) value(key string) (string, error) {
+	cookie := cookies.Get(key)
+	if cookie == nil {
+		return "", http.ErrNoCookie
+	}
+	return cookie.Value, nil
+}
+
+// convert converts a value of a cookie with Name equals to key with fn.
+// http.ErrNoCookie is returned if there is no such cookie, *ValueError is returned if fn fails
+func (cookies HttpCookies) convert(key string, fn func(value string) error) error {
+	value, err := cookies.value(key)
+	if err != nil {
+		return err
+	}
+	if err := fn(value); err != nil {
+		return &ValueError{Name: key, Value: value, Err: err}
+	}
+	return nil
+}
+
+// GetInt returns a cookie value by key as int.
+// http.ErrNoCookie is returned if there is no such cookie, *ValueError is returned if the value is not an integer
+func (cookies HttpCookies) GetInt(key string) (int, error) {
+	var result int
+	err := cookies.convert(key, func(value string) (err error) {
+		result, err = strconv.Atoi(value)
+		return
+	})
+	return result, err
+}
+
+// GetInt64 returns a cookie value by key as int64, see GetInt
+func (cookies HttpCookies) GetInt64(key string) (int64, error) {
+	var result int64
+	err := cookies.convert(key, func(value string) (err error) {
+		result, err = strconv.ParseInt(value, 10, 64)
+		return
+	})
+	return result, err
+}
+
+// GetFloat returns a cookie value by key as float64, see GetInt
+func (cookies HttpCookies) GetFloat(key string) (float64, error) {
+	var result float64
+	err := cookies.convert(key, func(value string) (err error) {
+		result, err = strconv.ParseFloat(value, 64)
+		return
+	})
+	return result, err
+}
+
+// GetBool returns a cookie value by key as bool. Values are parsed by strconv.ParseBool, see GetInt
+func (cookies HttpCookies) GetBool(key string) (bool, error) {
+	var result bool
+	err := cookies.convert(key, func(value string) (err error) {
+		result, err = strconv.ParseBool(value)
+		return
+	})
+	return result, err
+}
+
+// GetDuration returns a cookie value by key as time.Duration parsed by time.ParseDuration, see GetInt
+func (cookies HttpCookies) GetDuration(key string) (time.Duration, error) {
+	var result time.Duration
+	err := cookies.convert(key, func(value string) (err error) {
+		result, err = time.ParseDuration(value)
+		return
+	})
+	return result, err
+}
+
+// GetTime returns a cookie value by key as time.Time parsed with layout, time.RFC3339 if layout is empty.
+// See GetInt
+func (cookies HttpCookies) GetTime(key string, layout string) (time.Time, error) {
+	if layout == "" {
+		layout = time.RFC3339
+	}
+	var result time.Time
+	err := cookies.convert(key, func(value string) (err error) {
+		result, err = time.Parse(layout, value)
+		return
+	})
+	return result, err
+}
+
+// GetBase64 returns a cookie value by key decoded from base64, standard or URL safe, with or without padding.
+// See GetInt
+func (cookies HttpCookies) GetBase64(key string) ([]byte, error) {
+	var result []byte
+	err := cookies.convert(key, func(value string) (err error) {
+		result, err = decodeBase64(value)
+		return
+	})
+	return result, err
+}
+
+// GetJSON unmarshals a cookie value by key into out. The value should be JSON encoded with base64
+// as JSONValue makes it, since cookie values can not hold quotes and commas. See GetInt
+func (cookies HttpCookies) GetJSON(key string, out interface{}) error {
+	return cookies.convert(key, func(value string) error { return unmarshalJSONValue(value, out) })
+}
+
+// JSONValue returns v marshaled to JSON and encoded with URL safe base64 to be a cookie value
+func JSONValue(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// unmarshalJSONValue unmarshals value made by JSONValue into out
+func unmarshalJSONValue(value string, out interface{}) error {
+	b, err := decodeBase64(value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, out)
+}
+
+// decodeBase64 decodes s encoded with standard or URL safe base64 with or without padding
+func decodeBase64(s string) ([]byte, error) {
+	s = strings.TrimRight(s, "=")
+	if strings.ContainsAny(s, "+/") {
+		return base64.RawStdEncoding.DecodeString(s)
+	}
+	return base64.RawURLEncoding.DecodeString(s)
+}
+
+// Bind sets fields of a struct pointed by out from cookies named by `cookie:"name"` field tags.
+// Fields of missing cookies are left as they are unless the tag has a "required" option,
+// like `cookie:"cart,required"`, then http.ErrNoCookie is returned. Untagged fields and fields tagged "-" are skipped.
+// Strings, booleans, numbers, time.Duration, []byte in base64, types implementing encoding.TextUnmarshaler
+// including time.Time in RFC 3339 and pointers to them are converted as typed getters do,
+// other structs, maps and slices are decoded as GetJSON does.
+// Conversion failures are returned as *ValueError
+func (cookies HttpCookies) Bind(out interface{}) error {
+	v := reflect.ValueOf(out)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return ErrorNotStructPointer
+	}
+	v = v.Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag, ok := field.Tag.Lookup("cookie")
+		if !ok || tag == "-" || field.PkgPath != "" {
+			continue
+		}
+		name, options := tag, ""
+		if i := strings.Index(tag, ","); i >= 0 {
+			name, options = tag[:i], tag[i+1:]
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		value, err := cookies.value(name)
+		if err != nil {
+			if options == "required" {
+				return fmt.Errorf("%w: %s", err, name)
+			}
+			continue
+		}
+		if err := setField(v.Field(i), value); err != nil {
+			return &ValueError{Name: name, Value: value, Err: err}
+		}
+	}
+	return nil
+}
+
+// durationType is a reflect.Type of time.Duration
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// setField sets field to value converted to the field type
+func setField(field reflect.Value, value string) error {
+	if field.Kind() == reflect.Ptr {
+		target := reflect.New(field.Type().Elem())
+		if err := setField(target.Elem(), value); err != nil {
+			return err
+		}
+		field.Set(target)
+		return nil
+	}
+	if unmarshaler, ok := field.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return unmarshaler.UnmarshalText([]byte(value))
+	}
+	if field.Type() == durationType {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	case reflect.Slice:
+		if field.Type().Elem().Kind() == reflect.Uint8 {
+			b, err := decodeBase64(value)
+			if err != nil {
+				return err
+			}
+			field.SetBytes(b)
+			return nil
+		}
+		return unmarshalJSONValue(value, field.Addr().Interface())
+	case reflect.Struct, reflect.Map, reflect.Array:
+		return unmarshalJSONValue(value, field.Addr().Interface())
+	default:
+		return ErrorUnsupportedType
+	}
+	return nil
+}
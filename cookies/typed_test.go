@@ -0,0 +1,102 @@
+package cookies
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Typed values", func() {
+	type preferences struct {
+		Theme string   `json:"theme"`
+		Tags  []string `json:"tags"`
+	}
+
+	It("checks typed getters", func() {
+		prefs, err := JSONValue(preferences{Theme: "dark", Tags: []string{"a", "b"}})
+		Expect(err).NotTo(HaveOccurred())
+		cookies := New("n=42; big=9000000000; f=1.5; b=true; d=90s; t=2020-01-02T03:04:05Z; " +
+			"bytes=aGVsbG8; prefs=" + prefs + "; bad=x")
+
+		Expect(cookies.GetInt("n")).To(Equal(42))
+		Expect(cookies.GetInt64("big")).To(Equal(int64(9000000000)))
+		Expect(cookies.GetFloat("f")).To(Equal(1.5))
+		Expect(cookies.GetBool("b")).To(BeTrue())
+		Expect(cookies.GetDuration("d")).To(Equal(90 * time.Second))
+		Expect(cookies.GetTime("t", "")).To(Equal(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)))
+		Expect(cookies.GetBase64("bytes")).To(Equal([]byte("hello")))
+		var p preferences
+		Expect(cookies.GetJSON("prefs", &p)).To(Succeed())
+		Expect(p).To(Equal(preferences{Theme: "dark", Tags: []string{"a", "b"}}))
+
+		_, err = cookies.GetInt("missing")
+		Expect(err).To(Equal(http.ErrNoCookie))
+		getters := map[string]func() error{
+			"GetInt":      func() error { _, err := cookies.GetInt("bad"); return err },
+			"GetBool":     func() error { _, err := cookies.GetBool("bad"); return err },
+			"GetTime":     func() error { _, err := cookies.GetTime("bad", time.RFC1123); return err },
+			"GetDuration": func() error { _, err := cookies.GetDuration("bad"); return err },
+			"GetJSON":     func() error { return cookies.GetJSON("n", &p) },
+		}
+		for name, getter := range getters {
+			By(fmt.Sprintf("testing case %s", name))
+			var valueErr *ValueError
+			Expect(errors.As(getter(), &valueErr)).To(BeTrue())
+		}
+		var numErr *strconv.NumError
+		_, err = cookies.GetInt("bad")
+		Expect(errors.As(err, &numErr)).To(BeTrue())
+		Expect(err.Error()).To(Equal(`cookie bad="x": strconv.Atoi: parsing "x": invalid syntax`))
+	})
+
+	It("checks Bind", func() {
+		type cart struct {
+			ID       string        `cookie:"cart_id,required"`
+			Count    uint8         `cookie:"count"`
+			Ratio    float32       `cookie:"ratio"`
+			Enabled  *bool         `cookie:"enabled"`
+			TTL      time.Duration `cookie:"ttl"`
+			Since    time.Time     `cookie:"since"`
+			Token    []byte        `cookie:"token"`
+			Prefs    preferences   `cookie:"prefs"`
+			Missing  string        `cookie:"missing"`
+			Skipped  string        `cookie:"-"`
+			Untagged string
+		}
+		prefs, err := JSONValue(preferences{Theme: "light"})
+		Expect(err).NotTo(HaveOccurred())
+		cookies := New("cart_id=c-1; count=3; ratio=0.5; enabled=1; ttl=1h; since=2020-01-02T03:04:05Z; " +
+			"token=aGk=; prefs=" + prefs + "; Skipped=x; Untagged=y")
+
+		out := cart{Missing: "default"}
+		Expect(cookies.Bind(&out)).To(Succeed())
+		Expect(out.ID).To(Equal("c-1"))
+		Expect(out.Count).To(Equal(uint8(3)))
+		Expect(out.Ratio).To(Equal(float32(0.5)))
+		Expect(*out.Enabled).To(BeTrue())
+		Expect(out.TTL).To(Equal(time.Hour))
+		Expect(out.Since.Equal(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC))).To(BeTrue())
+		Expect(out.Token).To(Equal([]byte("hi")))
+		Expect(out.Prefs).To(Equal(preferences{Theme: "light"}))
+		Expect(out.Missing).To(Equal("default"))
+		Expect(out.Skipped).To(BeEmpty())
+		Expect(out.Untagged).To(BeEmpty())
+
+		By("testing errors")
+		err = New("cart_id=c-2; count=300").Bind(&out)
+		var valueErr *ValueError
+		Expect(errors.As(err, &valueErr)).To(BeTrue())
+		Expect(valueErr.Name).To(Equal("count"))
+		Expect(errors.Is(New("count=1").Bind(&out), http.ErrNoCookie)).To(BeTrue())
+		Expect(cookies.Bind(out)).To(Equal(ErrorNotStructPointer))
+		var unsupported struct {
+			C chan int `cookie:"count"`
+		}
+		Expect(errors.Is(cookies.Bind(&unsupported), ErrorUnsupportedType)).To(BeTrue())
+	})
+})
@@ -0,0 +1,137 @@
+package cert
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"time"
+)
+
+// errors
+var (
+	ErrorNoCertificate = errors.New("no certificate found")
+	ErrorExpired       = errors.New("certificate has expired")
+	ErrorNotYetValid   = errors.New("certificate is not yet valid")
+)
+
+// Info describes an X.509 certificate. Certificates with algorithms unknown to crypto/x509,
+// such as GOST R 34.10, are parsed too, though their public keys are not available
+type Info struct {
+	Subject      pkix.Name
+	Issuer       pkix.Name
+	SerialNumber *big.Int
+	NotBefore    time.Time
+	NotAfter     time.Time
+	KeyUsage     x509.KeyUsage
+	ExtKeyUsage  []x509.ExtKeyUsage
+	Certificate  *x509.Certificate // parsed certificate
+}
+
+// New returns Info for certificate c
+func New(c *x509.Certificate) Info {
+	return Info{
+		Subject:      c.Subject,
+		Issuer:       c.Issuer,
+		SerialNumber: c.SerialNumber,
+		NotBefore:    c.NotBefore,
+		NotAfter:     c.NotAfter,
+		KeyUsage:     c.KeyUsage,
+		ExtKeyUsage:  c.ExtKeyUsage,
+		Certificate:  c,
+	}
+}
+
+// Validate returns ErrorExpired or ErrorNotYetValid if the certificate is not valid at moment t
+func (i Info) Validate(t time.Time) error {
+	switch {
+	case t.After(i.NotAfter):
+		return ErrorExpired
+	case t.Before(i.NotBefore):
+		return ErrorNotYetValid
+	}
+	return nil
+}
+
+// ExpiresWithin returns true if the certificate expires within duration d after moment t or has already expired
+func (i Info) ExpiresWithin(t time.Time, d time.Duration) bool { return !t.Add(d).Before(i.NotAfter) }
+
+// KeyUsages returns names of key usages allowed by the certificate, e.g. "digitalSignature"
+func (i Info) KeyUsages() []string {
+	names := []string{}
+	for _, u := range keyUsageNames {
+		if i.KeyUsage&u.usage != 0 {
+			names = append(names, u.name)
+		}
+	}
+	return names
+}
+
+// keyUsageNames are names of key usages as RFC 5280 defines them
+var keyUsageNames = []struct {
+	usage x509.KeyUsage
+	name  string
+}{
+	{x509.KeyUsageDigitalSignature, "digitalSignature"},
+	{x509.KeyUsageContentCommitment, "nonRepudiation"},
+	{x509.KeyUsageKeyEncipherment, "keyEncipherment"},
+	{x509.KeyUsageDataEncipherment, "dataEncipherment"},
+	{x509.KeyUsageKeyAgreement, "keyAgreement"},
+	{x509.KeyUsageCertSign, "keyCertSign"},
+	{x509.KeyUsageCRLSign, "cRLSign"},
+	{x509.KeyUsageEncipherOnly, "encipherOnly"},
+	{x509.KeyUsageDecipherOnly, "decipherOnly"},
+}
+
+// Parse returns information on all certificates in data, which is either PEM or DER encoded.
+// It returns ErrorNoCertificate if there are no certificates in data
+func Parse(data []byte) ([]Info, error) {
+	certs, err := ParseCertificates(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(certs) == 0 {
+		return nil, ErrorNoCertificate
+	}
+	infos := make([]Info, len(certs))
+	for i, c := range certs {
+		infos[i] = New(c)
+	}
+	return infos, nil
+}
+
+// ParseFile returns information on all certificates in a PEM or DER encoded file
+func ParseFile(path string) ([]Info, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// ParseCertificates parses all certificates from PEM or DER encoded data.
+// Non-certificate PEM blocks, e.g. private keys, are skipped
+func ParseCertificates(data []byte) ([]*x509.Certificate, error) {
+	block, rest := pem.Decode(data)
+	if block == nil {
+		if len(data) == 0 {
+			return []*x509.Certificate{}, nil
+		}
+		return x509.ParseCertificates(data)
+	}
+
+	certs := []*x509.Certificate{}
+	for ; block != nil; block, rest = pem.Decode(rest) {
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		c, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, c)
+	}
+	return certs, nil
+}
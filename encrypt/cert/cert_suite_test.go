@@ -0,0 +1,13 @@
+package cert_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestCert(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Cert Suite")
+}
@@ -0,0 +1,144 @@
+package cert
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Testing with Ginkgo", func() {
+	now := time.Now()
+
+	It("parses GOST certificates", func() {
+		infos, err := Parse([]byte(gostCertificate))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(infos).To(HaveLen(1))
+		info := infos[0]
+		Expect(info.Subject.CommonName).To(Equal(`ООО "Рога и копыта"`))
+		Expect(info.Issuer.CommonName).To(Equal(`ООО "Рога и копыта"`))
+		Expect(info.SerialNumber.String()).To(Equal("13497580648002279915"))
+		Expect(info.NotBefore).To(Equal(time.Date(2016, 8, 5, 8, 38, 11, 0, time.UTC)))
+		Expect(info.NotAfter).To(Equal(time.Date(2019, 1, 22, 8, 38, 11, 0, time.UTC)))
+		Expect(info.Certificate.PublicKeyAlgorithm).To(Equal(x509.UnknownPublicKeyAlgorithm))
+		Expect(info.Validate(now)).To(MatchError(ErrorExpired))
+	})
+
+	It("parses PEM and DER data", func() {
+		der := createCertificate(now.Add(-time.Hour), now.Add(time.Hour))
+		pemData := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+		keyData := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte("key")})
+
+		testCases := map[string]struct {
+			data  []byte
+			count int
+		}{
+			"DER":              {der, 1},
+			"concatenated DER": {append(append([]byte{}, der...), der...), 2},
+			"PEM":              {pemData, 1},
+			"PEM with key":     {append(append(keyData, pemData...), pemData...), 2},
+		}
+		for name, tc := range testCases {
+			By(fmt.Sprintf("testing case %s", name))
+			infos, err := Parse(tc.data)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(infos).To(HaveLen(tc.count))
+			Expect(infos[0].Subject.CommonName).To(Equal("test"))
+		}
+
+		_, err := Parse(keyData)
+		Expect(err).To(MatchError(ErrorNoCertificate))
+		_, err = Parse(nil)
+		Expect(err).To(MatchError(ErrorNoCertificate))
+		_, err = Parse([]byte("garbage"))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("checks ParseFile", func() {
+		tempPath, err := ioutil.TempDir("", "cert")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(tempPath)
+
+		p := filepath.Join(tempPath, "cert.cer")
+		Expect(ioutil.WriteFile(p, createCertificate(now, now.Add(time.Hour)), 0660)).To(Succeed())
+		infos, err := ParseFile(p)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(infos).To(HaveLen(1))
+		Expect(infos[0].KeyUsages()).To(Equal([]string{"digitalSignature", "keyEncipherment"}))
+		Expect(infos[0].ExtKeyUsage).To(Equal([]x509.ExtKeyUsage{x509.ExtKeyUsageEmailProtection}))
+
+		_, err = ParseFile(filepath.Join(tempPath, "missing"))
+		Expect(os.IsNotExist(err)).To(BeTrue())
+	})
+
+	It("checks Validate and ExpiresWithin", func() {
+		testCases := []struct {
+			name                string
+			notBefore, notAfter time.Time
+			err                 error
+			expiresWithinDay    bool
+		}{
+			{"valid", now.Add(-time.Hour), now.Add(48 * time.Hour), nil, false},
+			{"expiring", now.Add(-time.Hour), now.Add(time.Hour), nil, true},
+			{"expired", now.Add(-2 * time.Hour), now.Add(-time.Hour), ErrorExpired, true},
+			{"not yet valid", now.Add(time.Hour), now.Add(48 * time.Hour), ErrorNotYetValid, false},
+		}
+		for _, tc := range testCases {
+			By(fmt.Sprintf("testing case %s", tc.name))
+			infos, err := Parse(createCertificate(tc.notBefore, tc.notAfter))
+			Expect(err).NotTo(HaveOccurred())
+			if tc.err == nil {
+				Expect(infos[0].Validate(now)).To(Succeed())
+			} else {
+				Expect(infos[0].Validate(now)).To(MatchError(tc.err))
+			}
+			Expect(infos[0].ExpiresWithin(now, 24*time.Hour)).To(Equal(tc.expiresWithinDay))
+		}
+	})
+})
+
+// createCertificate returns a new DER encoded self-signed certificate valid between notBefore and notAfter
+func createCertificate(notBefore, notAfter time.Time) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).NotTo(HaveOccurred())
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test"},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageEmailProtection},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	Expect(err).NotTo(HaveOccurred())
+	return der
+}
+
+const gostCertificate = `-----BEGIN CERTIFICATE-----
+MIICtzCCAmQCCQC7UQoWC1zZ6zAKBgYqhQMCAgMFADCB4TELMAkGA1UEBhMCUlUx
+LDAqBgNVBAgMI9Ca0YDQsNGB0L3QvtC00LDRgNGB0LrQuNC5INC60YDQsNC5MRsw
+GQYDVQQHDBLQmtGA0LDRgdC90L7QtNCw0YAxKjAoBgNVBAoMIdCe0J7QniAi0KDQ
+vtCz0LAg0Lgg0LrQvtC/0YvRgtCwIjEYMBYGA1UECwwP0JjQoi3QvtGC0LTQtdC7
+MSowKAYDVQQDDCHQntCe0J4gItCg0L7Qs9CwINC4INC60L7Qv9GL0YLQsCIxFTAT
+BgkqhkiG9w0BCQEWBmFAYS5ydTAeFw0xNjA4MDUwODM4MTFaFw0xOTAxMjIwODM4
+MTFaMIHhMQswCQYDVQQGEwJSVTEsMCoGA1UECAwj0JrRgNCw0YHQvdC+0LTQsNGA
+0YHQutC40Lkg0LrRgNCw0LkxGzAZBgNVBAcMEtCa0YDQsNGB0L3QvtC00LDRgDEq
+MCgGA1UECgwh0J7QntCeICLQoNC+0LPQsCDQuCDQutC+0L/Ri9GC0LAiMRgwFgYD
+VQQLDA/QmNCiLdC+0YLQtNC10LsxKjAoBgNVBAMMIdCe0J7QniAi0KDQvtCz0LAg
+0Lgg0LrQvtC/0YvRgtCwIjEVMBMGCSqGSIb3DQEJARYGYUBhLnJ1MGMwHAYGKoUD
+AgITMBIGByqFAwICIwEGByqFAwICHgEDQwAEQNz1pBRgNdbt0/EmcAHtKX83YYS7
+JArNQBDAk7QMkGr2XNqpe8FFCqvH6aIeMPwcJ/CmpOR60Rugf3N4FJ3VA+AwCgYG
+KoUDAgIDBQADQQBXjYwQLuQqhdS7yhY0gh38behzPIdWUQaPZIu/+BYvZF8szXdu
+ID4lpcoZxRwQ37jX+suvd6koFC6V00gEnRCo
+-----END CERTIFICATE-----`
@@ -0,0 +1,86 @@
+package encrypt
+
+import (
+	"context"
+	"io"
+)
+
+// ContextSigner умеет подписать файл или поток с заданными параметрами подписи,
+// прерывая работу по завершении ctx
+type ContextSigner interface {
+	SignContext(ctx context.Context, fileIn string, fileOut string, opts SignOptions) error
+	SignStreamContext(ctx context.Context, w io.Writer, r io.Reader, opts SignOptions) error
+}
+
+// ContextEncryptor умеет зашифровать файл или поток с заданными параметрами шифрования,
+// прерывая работу по завершении ctx
+type ContextEncryptor interface {
+	EncryptContext(ctx context.Context, fileIn string, fileOut string, opts EncryptOptions) error
+	EncryptStreamContext(ctx context.Context, w io.Writer, r io.Reader, opts EncryptOptions) error
+}
+
+// ContextSignerEncryptor включает интерфейсы ContextSigner и ContextEncryptor
+type ContextSignerEncryptor interface {
+	ContextSigner
+	ContextEncryptor
+}
+
+// ContextVerifier умеет проверить цифровую подпись файла так же, как Verifier, прерывая работу по завершении ctx
+type ContextVerifier interface {
+	VerifyContext(ctx context.Context, fileIn string, fileOut string) (VerifyResult, error)
+}
+
+// ContextDecryptor умеет расшифровать файл так же, как Decryptor, прерывая работу по завершении ctx
+type ContextDecryptor interface {
+	DecryptContext(ctx context.Context, fileIn string, fileOut string) error
+}
+
+// ContextVerifierDecryptor включает интерфейсы ContextVerifier и ContextDecryptor
+type ContextVerifierDecryptor interface {
+	ContextVerifier
+	ContextDecryptor
+}
+
+// signContext signs fileIn with s using ContextSigner if s implements it
+func signContext(ctx context.Context, s Signer, fileIn string, fileOut string) error {
+	if cs, ok := s.(ContextSigner); ok {
+		return cs.SignContext(ctx, fileIn, fileOut, SignOptions{})
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.SignDER(fileIn, fileOut)
+}
+
+// encryptContext encrypts fileIn with e using ContextEncryptor if e implements it
+func encryptContext(ctx context.Context, e Encryptor, fileIn string, fileOut string) error {
+	if ce, ok := e.(ContextEncryptor); ok {
+		return ce.EncryptContext(ctx, fileIn, fileOut, EncryptOptions{})
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return e.Encrypt(fileIn, fileOut)
+}
+
+// verifyContext verifies fileIn with v using ContextVerifier if v implements it
+func verifyContext(ctx context.Context, v Verifier, fileIn string, fileOut string) (VerifyResult, error) {
+	if cv, ok := v.(ContextVerifier); ok {
+		return cv.VerifyContext(ctx, fileIn, fileOut)
+	}
+	if err := ctx.Err(); err != nil {
+		return VerifyResult{}, err
+	}
+	return v.Verify(fileIn, fileOut)
+}
+
+// decryptContext decrypts fileIn with d using ContextDecryptor if d implements it
+func decryptContext(ctx context.Context, d Decryptor, fileIn string, fileOut string) error {
+	if cd, ok := d.(ContextDecryptor); ok {
+		return cd.DecryptContext(ctx, fileIn, fileOut)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return d.Decrypt(fileIn, fileOut)
+}
@@ -1,5 +1,16 @@
 package encrypt
 
+import (
+	"crypto/x509"
+	"errors"
+	"io"
+)
+
+// errors
+var (
+	ErrorInvalidSignature = errors.New("signature verification failed")
+)
+
 // Signer умеет подписать файл цифровой подписью в формате DER, которая туда же и помещается
 type Signer interface {
 	SignDER(fileIn string, fileOut string) error
@@ -15,3 +26,43 @@ type SignerEncryptor interface {
 	Signer
 	Encryptor
 }
+
+// Verifier умеет проверить цифровую подпись файла в формате DER и записать подписанное содержимое в fileOut.
+// Если подпись неверна, возвращается ошибка ErrorInvalidSignature
+type Verifier interface {
+	Verify(fileIn string, fileOut string) (VerifyResult, error)
+}
+
+// Decryptor умеет расшифровать файл в формате DER
+type Decryptor interface {
+	Decrypt(fileIn string, fileOut string) error
+}
+
+// VerifierDecryptor включает интерфейсы Verifier и Decryptor
+type VerifierDecryptor interface {
+	Verifier
+	Decryptor
+}
+
+// VerifyResult describes a successfully verified signature
+type VerifyResult struct {
+	Signers    []*x509.Certificate // certificates of the signers
+	ChainValid bool                // true if signer certificates chain up to a trusted CA
+	ChainError error               // reason the chain did not validate, nil if ChainValid
+}
+
+// StreamSigner умеет подписать данные из r, записав их вместе с цифровой подписью в формате DER в w
+type StreamSigner interface {
+	SignDERStream(w io.Writer, r io.Reader) error
+}
+
+// StreamEncryptor умеет зашифровать данные из r, записав результат в формате DER в w
+type StreamEncryptor interface {
+	EncryptStream(w io.Writer, r io.Reader) error
+}
+
+// StreamSignerEncryptor включает интерфейсы StreamSigner и StreamEncryptor
+type StreamSignerEncryptor interface {
+	StreamSigner
+	StreamEncryptor
+}
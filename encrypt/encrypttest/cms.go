@@ -0,0 +1,559 @@
+package encrypttest
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/big"
+
+	"github.com/mtfelian/utils/encrypt"
+)
+
+// errors
+var (
+	ErrorMalformed      = errors.New("malformed CMS message")
+	ErrorUnsupported    = errors.New("unsupported CMS algorithm")
+	ErrorUnsupportedKey = errors.New("unsupported key type")
+	ErrorNoRecipient    = errors.New("message is not encrypted for our certificate")
+	ErrorNoSigner       = errors.New("signer certificate not found in message")
+	ErrorNoRecipients   = errors.New("no recipient certificates")
+	ErrorNoCertificate  = errors.New("no certificate")
+)
+
+// object identifiers
+var (
+	oidData          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidSignedData    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidEnvelopedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 3}
+	oidMessageDigest = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidRSA           = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+	oidECDSASHA256   = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+	oidSHA256        = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidAES256CBC     = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+)
+
+// digestAlgorithms are digest algorithms accepted by Verify
+var digestAlgorithms = map[string]crypto.Hash{
+	"1.3.14.3.2.26":          crypto.SHA1,
+	oidSHA256.String():       crypto.SHA256,
+	"2.16.840.1.101.3.4.2.2": crypto.SHA384,
+	"2.16.840.1.101.3.4.2.3": crypto.SHA512,
+}
+
+// contentCiphers are content encryption algorithms accepted by Decrypt with their key sizes
+var contentCiphers = map[string]int{
+	"2.16.840.1.101.3.4.1.2":  16,
+	"2.16.840.1.101.3.4.1.22": 24,
+	oidAES256CBC.String():     32,
+}
+
+// Params is a set of CMS parameters
+type Params struct {
+	Certificate *x509.Certificate   // our certificate, used to sign and to find our recipient info
+	PrivateKey  crypto.Signer       // our RSA or ECDSA private key, RSA is needed to decrypt
+	Recipients  []*x509.Certificate // certificates of RSA recipients to encrypt for
+	Roots       *x509.CertPool      // trusted roots to validate signer chains, system roots if nil
+}
+
+// CMS is an in-process encrypt.SignerEncryptor and encrypt.VerifierDecryptor producing DER CMS
+// with standard library RSA and ECDSA keys. Signatures use SHA-256 without signed attributes
+// just like `openssl smime -sign -nodetach -noattr` makes them, encryption uses AES-256-CBC
+// with RSA PKCS#1 v1.5 key transport. It is meant for tests, not for production use
+type CMS struct{ params Params }
+
+var (
+	_ encrypt.SignerEncryptor       = &CMS{}
+	_ encrypt.StreamSignerEncryptor = &CMS{}
+	_ encrypt.VerifierDecryptor     = &CMS{}
+)
+
+// New returns a new CMS with given params
+func New(params Params) *CMS { return &CMS{params: params} }
+
+// SignDER signs file fileIn writing the data together with the signature into fileOut
+func (c *CMS) SignDER(fileIn string, fileOut string) error {
+	return transformFile(c.Sign, fileIn, fileOut)
+}
+
+// SignDERStream signs data from r writing it together with the signature into w
+func (c *CMS) SignDERStream(w io.Writer, r io.Reader) error { return transformStream(c.Sign, w, r) }
+
+// Encrypt encrypts file fileIn for recipients writing the result into fileOut
+func (c *CMS) Encrypt(fileIn string, fileOut string) error {
+	return transformFile(c.EncryptBytes, fileIn, fileOut)
+}
+
+// EncryptStream encrypts data from r for recipients writing the result into w
+func (c *CMS) EncryptStream(w io.Writer, r io.Reader) error {
+	return transformStream(c.EncryptBytes, w, r)
+}
+
+// Decrypt decrypts file fileIn with our private key writing the result into fileOut
+func (c *CMS) Decrypt(fileIn string, fileOut string) error {
+	return transformFile(c.DecryptBytes, fileIn, fileOut)
+}
+
+// Verify verifies the signature of file fileIn writing the signed data into fileOut
+func (c *CMS) Verify(fileIn string, fileOut string) (encrypt.VerifyResult, error) {
+	data, err := ioutil.ReadFile(fileIn)
+	if err != nil {
+		return encrypt.VerifyResult{}, err
+	}
+	content, result, err := c.VerifyBytes(data)
+	if err != nil {
+		return result, err
+	}
+	return result, ioutil.WriteFile(fileOut, content, 0600)
+}
+
+// contentInfo is CMS ContentInfo
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,tag:0"`
+}
+
+// issuerAndSerial is CMS IssuerAndSerialNumber
+type issuerAndSerial struct {
+	Issuer       asn1.RawValue
+	SerialNumber *big.Int
+}
+
+// signedData is CMS SignedData
+type signedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	EncapContentInfo encapContentInfo
+	Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue `asn1:"optional,tag:1"`
+	SignerInfos      []signerInfo  `asn1:"set"`
+}
+
+// encapContentInfo is CMS EncapsulatedContentInfo
+type encapContentInfo struct {
+	EContentType asn1.ObjectIdentifier
+	EContent     asn1.RawValue `asn1:"optional,explicit,tag:0"`
+}
+
+// signerInfo is CMS SignerInfo
+type signerInfo struct {
+	Version            int
+	SID                issuerAndSerial
+	DigestAlgorithm    pkix.AlgorithmIdentifier
+	SignedAttrs        asn1.RawValue `asn1:"optional,tag:0"`
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          []byte
+}
+
+// attribute is CMS Attribute
+type attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values asn1.RawValue
+}
+
+// envelopedData is CMS EnvelopedData with key transport recipients only
+type envelopedData struct {
+	Version              int
+	RecipientInfos       []keyTransRecipientInfo `asn1:"set"`
+	EncryptedContentInfo encryptedContentInfo
+}
+
+// keyTransRecipientInfo is CMS KeyTransRecipientInfo
+type keyTransRecipientInfo struct {
+	Version                int
+	RID                    issuerAndSerial
+	KeyEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedKey           []byte
+}
+
+// encryptedContentInfo is CMS EncryptedContentInfo
+type encryptedContentInfo struct {
+	ContentType                asn1.ObjectIdentifier
+	ContentEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedContent           asn1.RawValue `asn1:"tag:0,optional"`
+}
+
+// Sign returns data together with the signature as DER CMS SignedData.
+// It returns ErrorNoCertificate if Certificate is not set
+func (c *CMS) Sign(data []byte) ([]byte, error) {
+	if c.params.Certificate == nil {
+		return nil, ErrorNoCertificate
+	}
+	signatureAlgorithm, err := signatureAlgorithm(c.params.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	digest := crypto.SHA256.New()
+	digest.Write(data)
+	signature, err := c.params.PrivateKey.Sign(rand.Reader, digest.Sum(nil), crypto.SHA256)
+	if err != nil {
+		return nil, err
+	}
+
+	eContent, err := asn1.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	sd := signedData{
+		Version:          1,
+		DigestAlgorithms: []pkix.AlgorithmIdentifier{{Algorithm: oidSHA256}},
+		EncapContentInfo: encapContentInfo{
+			EContentType: oidData,
+			EContent:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: eContent},
+		},
+		Certificates: asn1.RawValue{
+			Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: c.params.Certificate.Raw,
+		},
+		SignerInfos: []signerInfo{{
+			Version:            1,
+			SID:                sid(c.params.Certificate),
+			DigestAlgorithm:    pkix.AlgorithmIdentifier{Algorithm: oidSHA256},
+			SignatureAlgorithm: signatureAlgorithm,
+			Signature:          signature,
+		}},
+	}
+	return marshalContentInfo(oidSignedData, sd)
+}
+
+// VerifyBytes verifies DER CMS SignedData and returns the signed data.
+// Signed attributes, if any, are checked against the data digest.
+// It returns encrypt.ErrorInvalidSignature if a signature does not match
+func (c *CMS) VerifyBytes(data []byte) ([]byte, encrypt.VerifyResult, error) {
+	result := encrypt.VerifyResult{}
+	var sd signedData
+	if err := unmarshalContentInfo(data, oidSignedData, &sd); err != nil {
+		return nil, result, err
+	}
+	var content []byte
+	if _, err := asn1.Unmarshal(sd.EncapContentInfo.EContent.Bytes, &content); err != nil {
+		return nil, result, ErrorMalformed
+	}
+	certs, err := x509.ParseCertificates(sd.Certificates.Bytes)
+	if err != nil {
+		return nil, result, ErrorMalformed
+	}
+	if len(sd.SignerInfos) == 0 {
+		return nil, result, encrypt.ErrorInvalidSignature
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs {
+		intermediates.AddCert(cert)
+	}
+	result.ChainValid = true
+	for _, si := range sd.SignerInfos {
+		cert := findCertificate(certs, si.SID)
+		if cert == nil {
+			return nil, result, ErrorNoSigner
+		}
+		if err := verifySignerInfo(si, cert, content); err != nil {
+			return nil, result, err
+		}
+		result.Signers = append(result.Signers, cert)
+
+		_, err := cert.Verify(x509.VerifyOptions{
+			Roots:         c.params.Roots,
+			Intermediates: intermediates,
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		})
+		if err != nil && result.ChainValid {
+			result.ChainValid, result.ChainError = false, err
+		}
+	}
+	return content, result, nil
+}
+
+// EncryptBytes returns data encrypted for recipients as DER CMS EnvelopedData.
+// It returns ErrorNoRecipients if Recipients are empty
+func (c *CMS) EncryptBytes(data []byte) ([]byte, error) {
+	if len(c.params.Recipients) == 0 {
+		return nil, ErrorNoRecipients
+	}
+	key, iv := make([]byte, 32), make([]byte, aes.BlockSize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(iv); err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	padded := pad(data, aes.BlockSize)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(padded, padded)
+
+	ed := envelopedData{}
+	for _, recipient := range c.params.Recipients {
+		pub, ok := recipient.PublicKey.(*rsa.PublicKey)
+		if !ok {
+			return nil, ErrorUnsupportedKey
+		}
+		encryptedKey, err := rsa.EncryptPKCS1v15(rand.Reader, pub, key)
+		if err != nil {
+			return nil, err
+		}
+		ed.RecipientInfos = append(ed.RecipientInfos, keyTransRecipientInfo{
+			RID:                    sid(recipient),
+			KeyEncryptionAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidRSA, Parameters: asn1.NullRawValue},
+			EncryptedKey:           encryptedKey,
+		})
+	}
+	ivParam, err := asn1.Marshal(iv)
+	if err != nil {
+		return nil, err
+	}
+	ed.EncryptedContentInfo = encryptedContentInfo{
+		ContentType:                oidData,
+		ContentEncryptionAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidAES256CBC, Parameters: asn1.RawValue{FullBytes: ivParam}},
+		EncryptedContent:           asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, Bytes: padded},
+	}
+	return marshalContentInfo(oidEnvelopedData, ed)
+}
+
+// DecryptBytes decrypts DER CMS EnvelopedData with our private key.
+// It returns ErrorNoCertificate if Certificate is not set
+func (c *CMS) DecryptBytes(data []byte) ([]byte, error) {
+	if c.params.Certificate == nil {
+		return nil, ErrorNoCertificate
+	}
+	var ed envelopedData
+	if err := unmarshalContentInfo(data, oidEnvelopedData, &ed); err != nil {
+		return nil, err
+	}
+	decrypter, ok := c.params.PrivateKey.(crypto.Decrypter)
+	if !ok {
+		return nil, ErrorUnsupportedKey
+	}
+
+	our := sid(c.params.Certificate)
+	for _, ri := range ed.RecipientInfos {
+		if !sameSID(ri.RID, our) {
+			continue
+		}
+		if !ri.KeyEncryptionAlgorithm.Algorithm.Equal(oidRSA) {
+			return nil, ErrorUnsupported
+		}
+		key, err := decrypter.Decrypt(rand.Reader, ri.EncryptedKey, nil)
+		if err != nil {
+			return nil, err
+		}
+		return decryptContent(ed.EncryptedContentInfo, key)
+	}
+	return nil, ErrorNoRecipient
+}
+
+// decryptContent decrypts AES-CBC encrypted content with key
+func decryptContent(eci encryptedContentInfo, key []byte) ([]byte, error) {
+	keySize, ok := contentCiphers[eci.ContentEncryptionAlgorithm.Algorithm.String()]
+	if !ok {
+		return nil, ErrorUnsupported
+	}
+	var iv []byte
+	if _, err := asn1.Unmarshal(eci.ContentEncryptionAlgorithm.Parameters.FullBytes, &iv); err != nil {
+		return nil, ErrorMalformed
+	}
+	if len(key) != keySize || len(iv) != aes.BlockSize {
+		return nil, ErrorMalformed
+	}
+
+	content := eci.EncryptedContent.Bytes
+	if eci.EncryptedContent.IsCompound {
+		// constructed OCTET STRING made of primitive segments
+		content = nil
+		for rest := eci.EncryptedContent.Bytes; len(rest) > 0; {
+			var segment []byte
+			var err error
+			if rest, err = asn1.Unmarshal(rest, &segment); err != nil {
+				return nil, ErrorMalformed
+			}
+			content = append(content, segment...)
+		}
+	}
+	if len(content) == 0 || len(content)%aes.BlockSize != 0 {
+		return nil, ErrorMalformed
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	plain := make([]byte, len(content))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plain, content)
+	return unpad(plain, aes.BlockSize)
+}
+
+// verifySignerInfo checks signature of si made with cert over content
+func verifySignerInfo(si signerInfo, cert *x509.Certificate, content []byte) error {
+	hash, ok := digestAlgorithms[si.DigestAlgorithm.Algorithm.String()]
+	if !ok {
+		return ErrorUnsupported
+	}
+	h := hash.New()
+	h.Write(content)
+	digest := h.Sum(nil)
+
+	if len(si.SignedAttrs.FullBytes) > 0 {
+		if err := checkMessageDigest(si.SignedAttrs.Bytes, digest); err != nil {
+			return err
+		}
+		// signed attributes are signed as an explicit SET OF rather than as [0] IMPLICIT
+		signed := append([]byte{0x31}, si.SignedAttrs.FullBytes[1:]...)
+		h = hash.New()
+		h.Write(signed)
+		digest = h.Sum(nil)
+	}
+
+	switch pub := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		if rsa.VerifyPKCS1v15(pub, hash, digest, si.Signature) != nil {
+			return encrypt.ErrorInvalidSignature
+		}
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(pub, digest, si.Signature) {
+			return encrypt.ErrorInvalidSignature
+		}
+	default:
+		return ErrorUnsupportedKey
+	}
+	return nil
+}
+
+// checkMessageDigest returns encrypt.ErrorInvalidSignature if the message digest attribute
+// of signed attributes attrs does not equal digest
+func checkMessageDigest(attrs []byte, digest []byte) error {
+	for rest := attrs; len(rest) > 0; {
+		var attr attribute
+		var err error
+		if rest, err = asn1.Unmarshal(rest, &attr); err != nil {
+			return ErrorMalformed
+		}
+		if !attr.Type.Equal(oidMessageDigest) {
+			continue
+		}
+		var value []byte
+		if _, err := asn1.Unmarshal(attr.Values.Bytes, &value); err != nil {
+			return ErrorMalformed
+		}
+		if !bytes.Equal(value, digest) {
+			return encrypt.ErrorInvalidSignature
+		}
+		return nil
+	}
+	return encrypt.ErrorInvalidSignature
+}
+
+// signatureAlgorithm returns CMS signature algorithm identifier for key
+func signatureAlgorithm(key crypto.Signer) (pkix.AlgorithmIdentifier, error) {
+	if key == nil {
+		return pkix.AlgorithmIdentifier{}, ErrorUnsupportedKey
+	}
+	switch key.Public().(type) {
+	case *rsa.PublicKey:
+		return pkix.AlgorithmIdentifier{Algorithm: oidRSA, Parameters: asn1.NullRawValue}, nil
+	case *ecdsa.PublicKey:
+		return pkix.AlgorithmIdentifier{Algorithm: oidECDSASHA256}, nil
+	}
+	return pkix.AlgorithmIdentifier{}, ErrorUnsupportedKey
+}
+
+// sid returns IssuerAndSerialNumber of cert
+func sid(cert *x509.Certificate) issuerAndSerial {
+	return issuerAndSerial{Issuer: asn1.RawValue{FullBytes: cert.RawIssuer}, SerialNumber: cert.SerialNumber}
+}
+
+// sameSID returns true if a and b identify the same certificate
+func sameSID(a, b issuerAndSerial) bool {
+	return bytes.Equal(a.Issuer.FullBytes, b.Issuer.FullBytes) && a.SerialNumber.Cmp(b.SerialNumber) == 0
+}
+
+// findCertificate returns a certificate from certs identified by id
+func findCertificate(certs []*x509.Certificate, id issuerAndSerial) *x509.Certificate {
+	for _, cert := range certs {
+		if sameSID(sid(cert), id) {
+			return cert
+		}
+	}
+	return nil
+}
+
+// marshalContentInfo returns DER ContentInfo of given type wrapping content
+func marshalContentInfo(contentType asn1.ObjectIdentifier, content interface{}) ([]byte, error) {
+	inner, err := asn1.Marshal(content)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(contentInfo{
+		ContentType: contentType,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: inner},
+	})
+}
+
+// unmarshalContentInfo parses DER ContentInfo of given type into content
+func unmarshalContentInfo(data []byte, contentType asn1.ObjectIdentifier, content interface{}) error {
+	var ci contentInfo
+	if rest, err := asn1.Unmarshal(data, &ci); err != nil || len(rest) > 0 {
+		return ErrorMalformed
+	}
+	if !ci.ContentType.Equal(contentType) {
+		return ErrorMalformed
+	}
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, content); err != nil {
+		return ErrorMalformed
+	}
+	return nil
+}
+
+// pad appends PKCS#7 padding to data
+func pad(data []byte, blockSize int) []byte {
+	n := blockSize - len(data)%blockSize
+	return append(append([]byte{}, data...), bytes.Repeat([]byte{byte(n)}, n)...)
+}
+
+// unpad removes PKCS#7 padding from data
+func unpad(data []byte, blockSize int) ([]byte, error) {
+	n := int(data[len(data)-1])
+	if n == 0 || n > blockSize || n > len(data) {
+		return nil, ErrorMalformed
+	}
+	for _, b := range data[len(data)-n:] {
+		if int(b) != n {
+			return nil, ErrorMalformed
+		}
+	}
+	return data[:len(data)-n], nil
+}
+
+// transformFile reads fileIn, transforms its contents with fn and writes the result into fileOut
+func transformFile(fn func([]byte) ([]byte, error), fileIn string, fileOut string) error {
+	data, err := ioutil.ReadFile(fileIn)
+	if err != nil {
+		return err
+	}
+	if data, err = fn(data); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fileOut, data, 0600)
+}
+
+// transformStream reads r, transforms its contents with fn and writes the result into w
+func transformStream(fn func([]byte) ([]byte, error), w io.Writer, r io.Reader) error {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if data, err = fn(data); err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
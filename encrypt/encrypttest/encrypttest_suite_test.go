@@ -0,0 +1,13 @@
+package encrypttest_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestEncrypttest(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Encrypttest Suite")
+}
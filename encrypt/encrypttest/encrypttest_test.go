@@ -0,0 +1,187 @@
+package encrypttest
+
+import (
+	"bytes"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/mtfelian/utils/compress/zip"
+	"github.com/mtfelian/utils/encrypt"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Testing with Ginkgo", func() {
+	var tempPath string
+
+	BeforeEach(func() {
+		var err error
+		tempPath, err = ioutil.TempDir("", "encrypttest")
+		Expect(err).NotTo(HaveOccurred())
+	})
+	AfterEach(func() { Expect(os.RemoveAll(tempPath)).To(Succeed()) })
+
+	newIdentity := func(ecdsa bool) Identity {
+		var id Identity
+		var err error
+		if ecdsa {
+			id, err = NewECDSAIdentity("sender")
+		} else {
+			id, err = NewRSAIdentity("sender")
+		}
+		Expect(err).NotTo(HaveOccurred())
+		return id
+	}
+
+	It("checks sign, encrypt, decrypt and verify flow", func() {
+		for i, ecdsa := range []bool{false, true} {
+			By(fmt.Sprintf("testing case %d, ecdsa %v", i, ecdsa))
+			sender, recipient := newIdentity(ecdsa), newIdentity(false)
+			roots := x509.NewCertPool()
+			roots.AddCert(sender.Certificate)
+
+			se := New(Params{
+				Certificate: sender.Certificate,
+				PrivateKey:  sender.PrivateKey,
+				Recipients:  []*x509.Certificate{recipient.Certificate},
+			})
+			vd := New(Params{Certificate: recipient.Certificate, PrivateKey: recipient.PrivateKey, Roots: roots})
+
+			fileIn := filepath.Join(tempPath, "data.xml")
+			Expect(ioutil.WriteFile(fileIn, []byte("<data/>"), 0600)).To(Succeed())
+			Expect(se.SignDER(fileIn, fileIn+".sgn")).To(Succeed())
+			Expect(se.Encrypt(fileIn+".sgn", fileIn+".sgn.enc")).To(Succeed())
+			Expect(vd.Decrypt(fileIn+".sgn.enc", fileIn+".dec")).To(Succeed())
+			Expect(ioutil.ReadFile(fileIn + ".dec")).To(Equal(mustRead(fileIn + ".sgn")))
+
+			result, err := vd.Verify(fileIn+".dec", fileIn+".out")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ioutil.ReadFile(fileIn + ".out")).To(Equal([]byte("<data/>")))
+			Expect(result.Signers).To(HaveLen(1))
+			Expect(result.Signers[0].Equal(sender.Certificate)).To(BeTrue())
+			Expect(result.ChainValid).To(BeTrue())
+			Expect(result.ChainError).NotTo(HaveOccurred())
+		}
+	})
+
+	It("checks stream helpers", func() {
+		id := newIdentity(false)
+		c := New(Params{Certificate: id.Certificate, PrivateKey: id.PrivateKey, Recipients: []*x509.Certificate{id.Certificate}})
+		signed, err := encrypt.SignBytes(c, []byte("payload"))
+		Expect(err).NotTo(HaveOccurred())
+		encrypted, err := encrypt.EncryptBytes(c, signed)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(bytes.Contains(encrypted, []byte("payload"))).To(BeFalse())
+
+		decrypted, err := c.DecryptBytes(encrypted)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(decrypted).To(Equal(signed))
+		data, result, err := c.VerifyBytes(decrypted)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(data).To(Equal([]byte("payload")))
+		Expect(result.ChainValid).To(BeFalse())
+		Expect(result.ChainError).To(HaveOccurred())
+	})
+
+	It("checks invalid signatures and foreign recipients", func() {
+		id, other := newIdentity(true), newIdentity(false)
+		c := New(Params{Certificate: id.Certificate, PrivateKey: id.PrivateKey, Recipients: []*x509.Certificate{other.Certificate}})
+
+		signed, err := c.Sign([]byte("original"))
+		Expect(err).NotTo(HaveOccurred())
+		tampered := bytes.Replace(signed, []byte("original"), []byte("modified"), 1)
+		_, _, err = c.VerifyBytes(tampered)
+		Expect(err).To(Equal(encrypt.ErrorInvalidSignature))
+		_, _, err = c.VerifyBytes([]byte("not a message"))
+		Expect(err).To(Equal(ErrorMalformed))
+
+		By("testing encryption for an ECDSA recipient")
+		_, err = New(Params{Recipients: []*x509.Certificate{id.Certificate}}).EncryptBytes([]byte("data"))
+		Expect(err).To(Equal(ErrorUnsupportedKey))
+
+		By("testing decryption by a foreign RSA key")
+		stranger := newIdentity(false)
+		encrypted, err := c.EncryptBytes([]byte("data"))
+		Expect(err).NotTo(HaveOccurred())
+		_, err = New(Params{Certificate: stranger.Certificate, PrivateKey: stranger.PrivateKey}).DecryptBytes(encrypted)
+		Expect(err).To(Equal(ErrorNoRecipient))
+	})
+
+	It("checks missing certificates", func() {
+		id := newIdentity(false)
+		_, err := New(Params{Certificate: id.Certificate, PrivateKey: id.PrivateKey}).EncryptBytes([]byte("data"))
+		Expect(err).To(Equal(ErrorNoRecipients))
+
+		c := New(Params{PrivateKey: id.PrivateKey})
+		_, err = c.Sign([]byte("data"))
+		Expect(err).To(Equal(ErrorNoCertificate))
+		_, err = c.DecryptBytes([]byte("data"))
+		Expect(err).To(Equal(ErrorNoCertificate))
+	})
+
+	It("checks chain validation with an intermediate issuer", func() {
+		ca := newIdentity(false)
+		leaf, err := NewIdentity("leaf", ca.PrivateKey, &ca)
+		Expect(err).NotTo(HaveOccurred())
+		roots := x509.NewCertPool()
+		roots.AddCert(ca.Certificate)
+
+		signed, err := New(Params{Certificate: leaf.Certificate, PrivateKey: leaf.PrivateKey}).Sign([]byte("data"))
+		Expect(err).NotTo(HaveOccurred())
+		_, result, err := New(Params{Roots: roots}).VerifyBytes(signed)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.ChainValid).To(BeTrue())
+		Expect(result.Signers[0].Subject.CommonName).To(Equal("leaf"))
+	})
+
+	It("checks Fake recording and reversibility", func() {
+		errSign := errors.New("sign failed")
+		f := &Fake{Signers: []*x509.Certificate{newIdentity(true).Certificate}}
+		p := encrypt.Pipeline{
+			SignerEncryptor:   f,
+			VerifierDecryptor: f,
+			Compressor:        zip.New(zip.Params{}),
+			Decompressor:      zip.New(zip.Params{}),
+			TempDir:           tempPath,
+		}
+		srcPath := filepath.Join(tempPath, "src")
+		Expect(os.MkdirAll(srcPath, 0700)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(srcPath, "a"), []byte("a data"), 0600)).To(Succeed())
+
+		fileOut := filepath.Join(tempPath, "out.enc")
+		Expect(p.SignEncrypt(fileOut, filepath.Join(srcPath, "a"))).To(Succeed())
+		Expect(bytes.HasPrefix(mustRead(fileOut), append(EncryptedMarker, SignedMarker...))).To(BeTrue())
+		result, err := p.DecryptVerify(fileOut, filepath.Join(tempPath, "dst"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Signers).To(Equal(f.Signers))
+		Expect(ioutil.ReadFile(filepath.Join(tempPath, "dst", "a"))).To(Equal([]byte("a data")))
+
+		calls := f.Calls()
+		Expect(calls).To(HaveLen(4))
+		for i, method := range []string{"SignDER", "Encrypt", "Decrypt", "Verify"} {
+			Expect(calls[i].Method).To(Equal(method))
+		}
+		Expect(calls[1].FileOut).To(Equal(fileOut))
+		Expect(calls[2].FileIn).To(Equal(fileOut))
+
+		By("testing configured errors and unsigned input")
+		f.Reset()
+		f.Errors = map[string]error{"SignDERStream": errSign}
+		_, err = encrypt.SignBytes(f, []byte("data"))
+		Expect(err).To(Equal(errSign))
+		Expect(f.Calls()).To(Equal([]Call{{Method: "SignDERStream"}}))
+		_, err = f.Verify(fileOut, filepath.Join(tempPath, "verified"))
+		Expect(err).To(Equal(encrypt.ErrorInvalidSignature))
+	})
+})
+
+// mustRead returns contents of file
+func mustRead(file string) []byte {
+	data, err := ioutil.ReadFile(file)
+	Expect(err).NotTo(HaveOccurred())
+	return data
+}
@@ -0,0 +1,133 @@
+package encrypttest
+
+import (
+	"bytes"
+	"crypto/x509"
+	"io"
+	"sync"
+
+	"github.com/mtfelian/utils/encrypt"
+)
+
+// markers prepended by Fake to signed and encrypted data
+var (
+	SignedMarker    = []byte("FAKE SIGNED\n")
+	EncryptedMarker = []byte("FAKE ENCRYPTED\n")
+)
+
+// Call is a recorded call of a Fake method. FileIn and FileOut are empty for stream methods
+type Call struct {
+	Method  string
+	FileIn  string
+	FileOut string
+}
+
+// Fake is a recording encrypt.SignerEncryptor and encrypt.VerifierDecryptor.
+// Instead of cryptography it prepends markers to data, so anything it signs or encrypts
+// is accepted by its own Verify or Decrypt. Methods are safe for concurrent use
+type Fake struct {
+	// Errors are returned from methods by their names, like "SignDER" or "EncryptStream",
+	// before anything is written
+	Errors map[string]error
+	// Signers are returned by Verify in encrypt.VerifyResult
+	Signers []*x509.Certificate
+
+	mu    sync.Mutex
+	calls []Call
+}
+
+var (
+	_ encrypt.SignerEncryptor       = &Fake{}
+	_ encrypt.StreamSignerEncryptor = &Fake{}
+	_ encrypt.VerifierDecryptor     = &Fake{}
+)
+
+// Calls returns a copy of calls recorded so far in order they were made
+func (f *Fake) Calls() []Call {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Call{}, f.calls...)
+}
+
+// Reset forgets recorded calls
+func (f *Fake) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = nil
+}
+
+// record records a call and returns an error configured for the method
+func (f *Fake) record(method, fileIn, fileOut string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, Call{Method: method, FileIn: fileIn, FileOut: fileOut})
+	return f.Errors[method]
+}
+
+// SignDER writes contents of fileIn prefixed with SignedMarker into fileOut
+func (f *Fake) SignDER(fileIn string, fileOut string) error {
+	if err := f.record("SignDER", fileIn, fileOut); err != nil {
+		return err
+	}
+	return transformFile(wrap(SignedMarker), fileIn, fileOut)
+}
+
+// SignDERStream writes data from r prefixed with SignedMarker into w
+func (f *Fake) SignDERStream(w io.Writer, r io.Reader) error {
+	if err := f.record("SignDERStream", "", ""); err != nil {
+		return err
+	}
+	return transformStream(wrap(SignedMarker), w, r)
+}
+
+// Encrypt writes contents of fileIn prefixed with EncryptedMarker into fileOut
+func (f *Fake) Encrypt(fileIn string, fileOut string) error {
+	if err := f.record("Encrypt", fileIn, fileOut); err != nil {
+		return err
+	}
+	return transformFile(wrap(EncryptedMarker), fileIn, fileOut)
+}
+
+// EncryptStream writes data from r prefixed with EncryptedMarker into w
+func (f *Fake) EncryptStream(w io.Writer, r io.Reader) error {
+	if err := f.record("EncryptStream", "", ""); err != nil {
+		return err
+	}
+	return transformStream(wrap(EncryptedMarker), w, r)
+}
+
+// Verify writes contents of fileIn without SignedMarker into fileOut.
+// It returns encrypt.ErrorInvalidSignature if there is no marker
+func (f *Fake) Verify(fileIn string, fileOut string) (encrypt.VerifyResult, error) {
+	if err := f.record("Verify", fileIn, fileOut); err != nil {
+		return encrypt.VerifyResult{}, err
+	}
+	if err := transformFile(unwrap(SignedMarker, encrypt.ErrorInvalidSignature), fileIn, fileOut); err != nil {
+		return encrypt.VerifyResult{}, err
+	}
+	return encrypt.VerifyResult{Signers: f.Signers, ChainValid: true}, nil
+}
+
+// Decrypt writes contents of fileIn without EncryptedMarker into fileOut.
+// It returns ErrorMalformed if there is no marker
+func (f *Fake) Decrypt(fileIn string, fileOut string) error {
+	if err := f.record("Decrypt", fileIn, fileOut); err != nil {
+		return err
+	}
+	return transformFile(unwrap(EncryptedMarker, ErrorMalformed), fileIn, fileOut)
+}
+
+// wrap returns a function prepending marker to data
+func wrap(marker []byte) func([]byte) ([]byte, error) {
+	return func(data []byte) ([]byte, error) { return append(append([]byte{}, marker...), data...), nil }
+}
+
+// unwrap returns a function removing marker from data or returning err if there is no marker
+func unwrap(marker []byte, err error) func([]byte) ([]byte, error) {
+	return func(data []byte) ([]byte, error) {
+		if !bytes.HasPrefix(data, marker) {
+			return nil, err
+		}
+		return data[len(marker):], nil
+	}
+}
@@ -0,0 +1,89 @@
+package encrypttest
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"time"
+)
+
+// Identity is a certificate together with its private key
+type Identity struct {
+	Certificate *x509.Certificate
+	PrivateKey  crypto.Signer
+}
+
+// NewRSAIdentity returns a new self-signed RSA identity with given common name valid for a day
+func NewRSAIdentity(commonName string) (Identity, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return Identity{}, err
+	}
+	return NewIdentity(commonName, key, nil)
+}
+
+// NewECDSAIdentity returns a new self-signed ECDSA P-256 identity with given common name valid for a day.
+// ECDSA identities can sign and verify but can not be recipients of encrypted messages
+func NewECDSAIdentity(commonName string) (Identity, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return Identity{}, err
+	}
+	return NewIdentity(commonName, key, nil)
+}
+
+// NewIdentity returns a new identity with given common name and key valid for a day.
+// The certificate is issued by issuer, or is self-signed and may act as a CA if issuer is nil
+func NewIdentity(commonName string, key crypto.Signer, issuer *Identity) (Identity, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 62))
+	if err != nil {
+		return Identity{}, err
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageEmailProtection},
+		BasicConstraintsValid: true,
+	}
+	parent, parentKey := template, key
+	if issuer != nil {
+		parent, parentKey = issuer.Certificate, issuer.PrivateKey
+	} else {
+		template.IsCA = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, key.Public(), parentKey)
+	if err != nil {
+		return Identity{}, err
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		return Identity{}, err
+	}
+	return Identity{Certificate: certificate, PrivateKey: key}, nil
+}
+
+// CertificatePEM returns the certificate PEM encoded
+func (i Identity) CertificatePEM() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: i.Certificate.Raw})
+}
+
+// PrivateKeyPEM returns the private key PEM encoded in PKCS#8
+func (i Identity) PrivateKeyPEM() ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(i.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
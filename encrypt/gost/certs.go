@@ -0,0 +1,72 @@
+package gost
+
+import (
+	"time"
+
+	"github.com/mtfelian/utils/encrypt/cert"
+)
+
+// ParamCertificate is a certificate referenced by SSLParams
+type ParamCertificate struct {
+	Field string // SSLParams field name
+	Path  string // certificate file path
+	cert.Info
+}
+
+// Certificates parses certificates referenced by OurCertFilePath, ForeignCertFilePath,
+// RecipientCertFilePaths and CAFilePath. Empty fields are skipped, relative paths are resolved against Dir
+func (p SSLParams) Certificates() ([]ParamCertificate, error) {
+	files := []paramFile{
+		{"OurCertFilePath", p.OurCertFilePath, true},
+		{"ForeignCertFilePath", p.ForeignCertFilePath, true},
+	}
+	for _, path := range p.RecipientCertFilePaths {
+		files = append(files, paramFile{"RecipientCertFilePaths", path, true})
+	}
+	files = append(files, paramFile{"CAFilePath", p.CAFilePath, false})
+
+	certs := []ParamCertificate{}
+	for _, file := range files {
+		if file.path == "" {
+			continue
+		}
+		infos, err := cert.ParseFile(p.resolve(file.path))
+		if err != nil {
+			return nil, &ParamError{Field: file.field, Value: file.path, Err: err}
+		}
+		for _, info := range infos {
+			certs = append(certs, ParamCertificate{Field: file.field, Path: file.path, Info: info})
+		}
+	}
+	return certs, nil
+}
+
+// ExpiringCertificates returns certificates referenced by p which expire within d from now or have already expired.
+// It is useful to warn about certificates to be replaced soon
+func (p SSLParams) ExpiringCertificates(d time.Duration) ([]ParamCertificate, error) {
+	certs, err := p.Certificates()
+	if err != nil {
+		return nil, err
+	}
+	expiring, now := []ParamCertificate{}, time.Now()
+	for _, c := range certs {
+		if c.ExpiresWithin(now, d) {
+			expiring = append(expiring, c)
+		}
+	}
+	return expiring, nil
+}
+
+// checkValidity returns an error if any certificate in a file at path is not valid at moment t
+func checkValidity(path string, t time.Time) error {
+	infos, err := cert.ParseFile(path)
+	if err != nil {
+		return err
+	}
+	for _, info := range infos {
+		if err := info.Validate(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
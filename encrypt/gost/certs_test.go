@@ -0,0 +1,67 @@
+package gost
+
+import (
+	"errors"
+	"time"
+
+	"github.com/mtfelian/utils/encrypt/cert"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SSLParams certificates", func() {
+	var params SSLParams
+
+	BeforeEach(func() {
+		createTestFile()
+		params = getSSLParams()
+		params.OpenSSLPath = writeFakeOpenSSL("", 0)
+	})
+	AfterEach(func() { cleanup() })
+
+	It("checks Certificates", func() {
+		params.RecipientCertFilePaths = []string{params.OurCertFilePath}
+		certs, err := params.Certificates()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(certs).To(HaveLen(3))
+		fields := []string{}
+		for _, c := range certs {
+			fields = append(fields, c.Field)
+			Expect(c.Subject.CommonName).To(Equal(`ООО "Рога и копыта"`))
+		}
+		Expect(fields).To(Equal([]string{"OurCertFilePath", "ForeignCertFilePath", "RecipientCertFilePaths"}))
+
+		params.CAFilePath = params.OurPrivateKey
+		_, err = params.Certificates()
+		Expect(errors.Is(err, cert.ErrorNoCertificate)).To(BeTrue())
+		var paramErr *ParamError
+		Expect(errors.As(err, &paramErr)).To(BeTrue())
+		Expect(paramErr.Field).To(Equal("CAFilePath"))
+	})
+
+	It("checks ExpiringCertificates", func() {
+		params.ForeignCertFilePath = ""
+		// test certificate has expired in 2019
+		certs, err := params.ExpiringCertificates(0)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(certs).To(HaveLen(1))
+		Expect(certs[0].Field).To(Equal("OurCertFilePath"))
+		Expect(certs[0].Path).To(Equal(params.OurCertFilePath))
+		Expect(certs[0].NotAfter.Before(time.Now())).To(BeTrue())
+	})
+
+	It("refuses invalid certificates if asked to", func() {
+		_, err := NewGostSignerEncryptor(params)
+		Expect(err).NotTo(HaveOccurred())
+
+		params.RefuseInvalidCerts = true
+		_, err = NewGostSignerEncryptor(params)
+		Expect(errors.Is(err, cert.ErrorExpired)).To(BeTrue())
+		var paramErr *ParamError
+		Expect(errors.As(err, &paramErr)).To(BeTrue())
+		Expect(paramErr.Field).To(Equal("OurCertFilePath"))
+
+		_, err = NewGostVerifierDecryptor(params)
+		Expect(errors.Is(err, cert.ErrorExpired)).To(BeTrue())
+	})
+})
@@ -0,0 +1,163 @@
+package gost
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+
+	"github.com/mtfelian/utils/encrypt"
+	"github.com/mtfelian/utils/internal/command"
+)
+
+// ErrorKind classifies openssl failures
+type ErrorKind int
+
+// openssl failure kinds
+const (
+	KindUnknown          ErrorKind = iota // failure is not recognized
+	KindNoEngine                          // gost engine can not be loaded
+	KindWrongKey                          // private key can not be loaded or does not match the certificate
+	KindBadCertificate                    // certificate can not be loaded
+	KindCertExpired                       // certificate has expired or is not yet valid
+	KindInvalidSignature                  // signature does not match the signed data
+)
+
+// String implements fmt.Stringer
+func (k ErrorKind) String() string {
+	switch k {
+	case KindNoEngine:
+		return "no gost engine"
+	case KindWrongKey:
+		return "wrong private key"
+	case KindBadCertificate:
+		return "bad certificate"
+	case KindCertExpired:
+		return "certificate expired"
+	case KindInvalidSignature:
+		return "invalid signature"
+	}
+	return "unknown failure"
+}
+
+// errorKindPatterns map openssl stderr substrings in lower case to failure kinds.
+// Patterns are checked in order, so more specific ones go first
+var errorKindPatterns = []struct {
+	pattern string
+	kind    ErrorKind
+}{
+	{"invalid engine", KindNoEngine},
+	{"can't use that engine", KindNoEngine},
+	{"could not load the shared library", KindNoEngine},
+	{"certificate has expired", KindCertExpired},
+	{"certificate is not yet valid", KindCertExpired},
+	{"key values mismatch", KindWrongKey},
+	{"unable to load private key", KindWrongKey},
+	{"unable to load key", KindWrongKey},
+	{"could not read private key", KindWrongKey},
+	{"no recipient matches", KindWrongKey},
+	{"bad decrypt", KindWrongKey},
+	{"unable to load certificate", KindBadCertificate},
+	{"could not read certificate", KindBadCertificate},
+	{"verification failure", KindInvalidSignature},
+	{"signature failure", KindInvalidSignature},
+	{"digest failure", KindInvalidSignature},
+}
+
+// kindErrors are package errors matched by CommandError of a kind with errors.Is
+var kindErrors = map[ErrorKind]error{
+	KindNoEngine:         ErrorNoGostEngine,
+	KindInvalidSignature: encrypt.ErrorInvalidSignature,
+}
+
+// redactedFlags are openssl flags with values not to be shown in errors
+var redactedFlags = map[string]bool{"-inkey": true, "-passin": true, "-pass": true, "-k": true}
+
+// CommandError describes a failed openssl command
+type CommandError struct {
+	Args     []string  // command line including the executable, private key path is redacted
+	ExitCode int       // exit code, -1 if the command was not started or was killed
+	Stderr   string    // captured standard error output
+	Kind     ErrorKind // failure classification
+	Err      error     // error returned by os/exec, or context error if the command was killed on timeout or cancellation
+}
+
+// Error implements error interface
+func (e *CommandError) Error() string {
+	msg := fmt.Sprintf("%s: %v", strings.Join(e.Args, " "), e.Err)
+	if e.Kind != KindUnknown {
+		msg += " (" + e.Kind.String() + ")"
+	}
+	if stderr := strings.TrimSpace(e.Stderr); stderr != "" {
+		msg += ": " + stderr
+	}
+	return msg
+}
+
+// Unwrap returns the underlying error
+func (e *CommandError) Unwrap() error { return e.Err }
+
+// Is makes errors.Is report ErrorNoGostEngine and encrypt.ErrorInvalidSignature for errors of matching kinds
+func (e *CommandError) Is(target error) bool {
+	err, ok := kindErrors[e.Kind]
+	return ok && err == target
+}
+
+// classify returns a failure kind for openssl stderr output
+func classify(stderr string) ErrorKind {
+	stderr = strings.ToLower(stderr)
+	for _, p := range errorKindPatterns {
+		if strings.Contains(stderr, p.pattern) {
+			return p.kind
+		}
+	}
+	return KindUnknown
+}
+
+// redact returns a copy of args with values of redactedFlags replaced
+func redact(args []string) []string {
+	result := append([]string{}, args...)
+	for i := 1; i < len(result); i++ {
+		if redactedFlags[result[i-1]] {
+			result[i] = "<redacted>"
+		}
+	}
+	return result
+}
+
+// runOpenSSL runs openssl executable at p.OpenSSLPath with args and returns its standard output.
+// On failure *CommandError is returned
+func runOpenSSL(ctx context.Context, p SSLParams, args ...string) ([]byte, error) {
+	stdout := &bytes.Buffer{}
+	err := runOpenSSLStream(ctx, p, nil, stdout, args...)
+	return stdout.Bytes(), err
+}
+
+// runOpenSSLStream runs openssl executable at p.OpenSSLPath with args, feeding it stdin and
+// writing its standard output into stdout. The process is killed with its children if ctx is done
+// or p.Timeout elapses. On failure *CommandError is returned
+func runOpenSSLStream(ctx context.Context, p SSLParams, stdin io.Reader, stdout io.Writer, args ...string) error {
+	stderr := &bytes.Buffer{}
+	err := p.runner().Run(ctx, p.OpenSSLPath, stdin, stdout, stderr, args...)
+	if err == nil {
+		return nil
+	}
+	cmdErr := &CommandError{
+		Args:     redact(append([]string{p.OpenSSLPath}, args...)),
+		ExitCode: -1,
+		Stderr:   stderr.String(),
+		Kind:     classify(stderr.String()),
+		Err:      err,
+	}
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		cmdErr.ExitCode = exitErr.ExitCode()
+	}
+	return cmdErr
+}
+
+// runner returns a command runner configured by p
+func (p SSLParams) runner() command.Runner {
+	return command.Runner{Timeout: p.Timeout, Env: p.Env, Dir: p.Dir}
+}
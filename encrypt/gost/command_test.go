@@ -0,0 +1,121 @@
+package gost
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mtfelian/utils/encrypt"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CommandError", func() {
+	BeforeEach(func() { createTestFile() })
+	AfterEach(func() { cleanup() })
+
+	It("checks classify", func() {
+		testCases := map[string]ErrorKind{
+			`invalid engine "gost"`: KindNoEngine,
+			"140:error:0B080074:x509 certificate routines:X509_check_private_key:key values mismatch": KindWrongKey,
+			"unable to load private key":           KindWrongKey,
+			"Verify error:certificate has expired": KindCertExpired,
+			"unable to load certificate":           KindBadCertificate,
+			"Verification failure":                 KindInvalidSignature,
+			"Error reading S/MIME message":         KindUnknown,
+			"":                                     KindUnknown,
+		}
+		for stderr, kind := range testCases {
+			By(fmt.Sprintf("testing case %q", stderr))
+			Expect(classify(stderr)).To(Equal(kind))
+		}
+	})
+
+	It("reports failed command with redacted key path", func() {
+		params := getSSLParams()
+		params.OpenSSLPath = writeFakeOpenSSL("Verify error:certificate has expired", 4)
+		err := gostSSL{params: params}.SignDER("in", "out")
+
+		var cmdErr *CommandError
+		Expect(errors.As(err, &cmdErr)).To(BeTrue())
+		Expect(cmdErr.ExitCode).To(Equal(4))
+		Expect(cmdErr.Kind).To(Equal(KindCertExpired))
+		Expect(cmdErr.Stderr).To(ContainSubstring("certificate has expired"))
+		Expect(cmdErr.Args[0]).To(Equal(params.OpenSSLPath))
+		Expect(cmdErr.Args).To(ContainElement("<redacted>"))
+		Expect(cmdErr.Args).NotTo(ContainElement(params.OurPrivateKey))
+		Expect(err.Error()).NotTo(ContainSubstring(params.OurPrivateKey))
+		Expect(err.Error()).To(ContainSubstring("certificate expired"))
+	})
+
+	It("matches package errors by kind", func() {
+		params := getSSLParams()
+		params.OpenSSLPath = writeFakeOpenSSL("Verification failure", 4)
+		_, err := gostSSL{params: params}.Verify("in", "out")
+		Expect(errors.Is(err, encrypt.ErrorInvalidSignature)).To(BeTrue())
+		Expect(errors.Is(err, ErrorNoGostEngine)).To(BeFalse())
+
+		params.OpenSSLPath = writeFakeOpenSSL(`invalid engine "gost"`, 1)
+		err = gostSSL{params: params}.Decrypt("in", "out")
+		Expect(errors.Is(err, ErrorNoGostEngine)).To(BeTrue())
+	})
+
+	It("reports commands that could not be started", func() {
+		params := getSSLParams()
+		params.OpenSSLPath = filepath.Join(testPath, "missing", "openssl")
+		err := gostSSL{params: params}.Encrypt("in", "out")
+		var cmdErr *CommandError
+		Expect(errors.As(err, &cmdErr)).To(BeTrue())
+		Expect(cmdErr.ExitCode).To(Equal(-1))
+		Expect(cmdErr.Kind).To(Equal(KindUnknown))
+	})
+
+	It("kills openssl on timeout and cancellation", func() {
+		params := getSSLParams()
+		params.OpenSSLPath = writeScript("openssl-hung", "sleep 30 &\nwait")
+		params.Timeout = 200 * time.Millisecond
+		start := time.Now()
+		err := gostSSL{params: params}.SignDER("in", "out")
+		Expect(errors.Is(err, context.DeadlineExceeded)).To(BeTrue())
+		Expect(time.Since(start)).To(BeNumerically("<", 10*time.Second))
+		var cmdErr *CommandError
+		Expect(errors.As(err, &cmdErr)).To(BeTrue())
+		Expect(cmdErr.ExitCode).To(Equal(-1))
+
+		By("testing cancelled context")
+		params.Timeout = 0
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(100*time.Millisecond, cancel)
+		_, err = gostSSL{params: params}.VerifyContext(ctx, "in", filepath.Join(testPath, "out"))
+		Expect(errors.Is(err, context.Canceled)).To(BeTrue())
+		Expect(filepath.Join(testPath, "out")).NotTo(BeAnExistingFile())
+
+		By("testing stalled stream input")
+		params.Timeout = 200 * time.Millisecond
+		stdin, stdinWriter := io.Pipe()
+		defer stdinWriter.Close()
+		start = time.Now()
+		err = gostSSL{params: params}.SignDERStream(ioutil.Discard, stdin)
+		Expect(errors.Is(err, context.DeadlineExceeded)).To(BeTrue())
+		Expect(time.Since(start)).To(BeNumerically("<", 10*time.Second))
+	})
+
+	It("passes Env and Dir to openssl", func() {
+		params := getSSLParams()
+		params.OpenSSLPath = writeScript("openssl-env", `echo "$OPENSSL_CONF"; pwd; cat`)
+		params.Env = []string{"OPENSSL_CONF=/gost-ssl/openssl.cnf"}
+		params.Dir = testPath
+		stdout := &bytes.Buffer{}
+		err := gostSSL{params: params}.SignStreamContext(context.Background(), stdout, strings.NewReader("data"), encrypt.SignOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		dir, err := filepath.EvalSymlinks(testPath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stdout.String()).To(Equal("/gost-ssl/openssl.cnf\n" + dir + "\ndata"))
+	})
+})
@@ -1,70 +1,325 @@
 package gost
 
 import (
-	"fmt"
+	"context"
+	"io"
+	"io/ioutil"
 	"os"
-	"os/exec"
 
-	"github.com/mtfelian/utils"
 	"github.com/mtfelian/utils/encrypt"
+	"github.com/mtfelian/utils/encrypt/cert"
 )
 
-type SSLParams struct {
-	OpenSSLPath         string // путь к исполняемому файлу openSSL
-	OurCertFilePath     string // путь к нашему сертификату
-	ForeignCertFilePath string // путь к чужому сертификату
-	OurPrivateKey       string // путь к нашему приватному ключу
-}
-
+// gostSSL подписывает, шифрует, проверяет подпись и расшифровывает данные с помощью openssl с движком gost.
+// При ошибке потоковых методов в w может оказаться частично записанный результат
 type gostSSL struct{ params SSLParams }
 
 func (s gostSSL) getParams() SSLParams { return s.params }
 
-// NewGostSignerEncryptor создаёт новый объект для подписи
-func NewGostSignerEncryptor(params SSLParams) encrypt.SignerEncryptor {
-	if !utils.FileExists(params.OpenSSLPath) {
-		fmt.Println("Не удаётся получить доступ к openSSL по заданному пути.")
-		os.Exit(1)
+var (
+	_ encrypt.SignerEncryptor          = gostSSL{}
+	_ encrypt.StreamSignerEncryptor    = gostSSL{}
+	_ encrypt.VerifierDecryptor        = gostSSL{}
+	_ encrypt.OptionsSigner            = gostSSL{}
+	_ encrypt.OptionsEncryptor         = gostSSL{}
+	_ encrypt.ContextSignerEncryptor   = gostSSL{}
+	_ encrypt.ContextVerifierDecryptor = gostSSL{}
+)
+
+// NewGostSignerEncryptor создаёт новый объект для подписи и шифрования.
+// Проверяется доступность openssl с движком gost, а также файлов OurCertFilePath,
+// OurPrivateKey, ForeignCertFilePath, если он задан, и RecipientCertFilePaths, а при заданном
+// RefuseInvalidCerts и срок действия сертификатов. Если не заданы ни ForeignCertFilePath,
+// ни RecipientCertFilePaths, возвращается ErrorNoRecipients. При ошибке возвращается *ParamError.
+// Возвращаемый объект также реализует encrypt.StreamSignerEncryptor, encrypt.OptionsSigner,
+// encrypt.OptionsEncryptor и encrypt.ContextSignerEncryptor
+func NewGostSignerEncryptor(params SSLParams) (encrypt.SignerEncryptor, error) {
+	files := []paramFile{
+		{"OurCertFilePath", params.OurCertFilePath, true},
+		{"OurPrivateKey", params.OurPrivateKey, false},
+	}
+	if len(params.recipients()) == 0 {
+		return nil, &ParamError{Field: "ForeignCertFilePath", Value: params.ForeignCertFilePath, Err: ErrorNoRecipients}
+	}
+	if params.ForeignCertFilePath != "" {
+		files = append(files, paramFile{"ForeignCertFilePath", params.ForeignCertFilePath, true})
+	}
+	for _, path := range params.RecipientCertFilePaths {
+		files = append(files, paramFile{"RecipientCertFilePaths", path, true})
+	}
+	return newGostSSL(params, files)
+}
+
+// NewGostVerifierDecryptor создаёт новый объект для проверки подписи и расшифровки.
+// Проверяется доступность openssl с движком gost, а также файлов OurCertFilePath,
+// OurPrivateKey и CAFilePath, если он задан, а при заданном RefuseInvalidCerts и срок действия
+// нашего сертификата. При ошибке возвращается *ParamError.
+// Возвращаемый объект также реализует encrypt.ContextVerifierDecryptor
+func NewGostVerifierDecryptor(params SSLParams) (encrypt.VerifierDecryptor, error) {
+	files := []paramFile{
+		{"OurCertFilePath", params.OurCertFilePath, true},
+		{"OurPrivateKey", params.OurPrivateKey, false},
+	}
+	if params.CAFilePath != "" {
+		files = append(files, paramFile{"CAFilePath", params.CAFilePath, false})
 	}
-	return &gostSSL{params: params}
+	return newGostSSL(params, files)
+}
+
+// newGostSSL проверяет параметры и файлы и возвращает новый gostSSL
+func newGostSSL(params SSLParams, files []paramFile) (*gostSSL, error) {
+	if err := params.validate(files); err != nil {
+		return nil, err
+	}
+	return &gostSSL{params: params}, nil
 }
 
 // SignDER подписывает файл с путём fileIn в формате DER
-// содержимое вместе с цифровой подписью записывается в файл с путём fileOut
+// содержимое вместе с цифровой подписью записывается в файл с путём fileOut.
+// Ошибки openssl возвращаются как *CommandError
 func (s gostSSL) SignDER(fileIn string, fileOut string) error {
+	return s.SignContext(context.Background(), fileIn, fileOut, encrypt.SignOptions{})
+}
+
+// SignDERStream подписывает данные из r, передавая их openssl через stdin,
+// содержимое вместе с цифровой подписью в формате DER записывается в w
+func (s gostSSL) SignDERStream(w io.Writer, r io.Reader) error {
+	return s.SignStreamContext(context.Background(), w, r, encrypt.SignOptions{})
+}
+
+// Sign подписывает файл с путём fileIn с параметрами opts, результат записывается в файл с путём fileOut
+func (s gostSSL) Sign(fileIn string, fileOut string, opts encrypt.SignOptions) error {
+	return s.SignContext(context.Background(), fileIn, fileOut, opts)
+}
+
+// SignStream подписывает данные из r с параметрами opts, передавая их openssl через stdin,
+// результат записывается в w
+func (s gostSSL) SignStream(w io.Writer, r io.Reader, opts encrypt.SignOptions) error {
+	return s.SignStreamContext(context.Background(), w, r, opts)
+}
+
+// SignContext аналогичен Sign, но завершает openssl при завершении ctx
+func (s gostSSL) SignContext(ctx context.Context, fileIn string, fileOut string, opts encrypt.SignOptions) error {
+	cmdParams, cleanup, err := s.signParams(opts)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	_, err = runOpenSSL(ctx, s.getParams(), append(cmdParams, "-in", fileIn, "-out", fileOut)...)
+	return err
+}
+
+// SignStreamContext аналогичен SignStream, но завершает openssl при завершении ctx
+func (s gostSSL) SignStreamContext(ctx context.Context, w io.Writer, r io.Reader, opts encrypt.SignOptions) error {
+	cmdParams, cleanup, err := s.signParams(opts)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	return runOpenSSLStream(ctx, s.getParams(), r, w, cmdParams...)
+}
+
+// signParams возвращает аргументы openssl для подписи данных из stdin, если не добавлен -in.
+// Дополнительные сертификаты собираются во временный файл, удаляемый функцией cleanup
+func (s gostSSL) signParams(opts encrypt.SignOptions) (cmdParams []string, cleanup func(), err error) {
 	// Пример командной строки:
 	// /gost-ssl/bin/openssl smime -sign -nodetach -signer certs/dirname/dirname.cer -inkey private.pem \
 	// -engine gost -gost89 -binary -noattr -outform DER -in test.xml -out test.xml.sgn
-	p := s.getParams()
-	cmdParams := []string{
-		"smime", "-sign", "-nodetach", "-signer", p.OurCertFilePath, "-inkey", p.OurPrivateKey,
-		"-engine", "gost", "-gost89", "-binary", "-noattr", "-outform", "DER",
-		"-in", fileIn,
-		"-out", fileOut,
+	p, cleanup := s.getParams(), func() {}
+	switch opts.Format {
+	case encrypt.FormatDER, encrypt.FormatPEM, encrypt.FormatSMIME:
+	default:
+		return nil, cleanup, encrypt.ErrorUnknownFormat
 	}
 
-	cmd := exec.Command(s.getParams().OpenSSLPath, cmdParams...)
-	// в первом параметре вывод команды
-	_, err := cmd.CombinedOutput()
-	//fmt.Println(string(output))
-	return err
+	cmdParams = []string{"smime", "-sign", "-signer", p.OurCertFilePath, "-inkey", p.OurPrivateKey,
+		"-engine", "gost", "-gost89", "-binary", "-outform", opts.Format.String()}
+	if !opts.Detached {
+		cmdParams = append(cmdParams, "-nodetach")
+	}
+	if !opts.Attributes {
+		cmdParams = append(cmdParams, "-noattr")
+	}
+	if len(opts.ExtraCerts) > 0 {
+		certFile, err := concatFiles(p, opts.ExtraCerts)
+		if err != nil {
+			return nil, cleanup, err
+		}
+		cmdParams, cleanup = append(cmdParams, "-certfile", certFile), func() { os.Remove(certFile) }
+	}
+	return cmdParams, cleanup, nil
 }
 
-// Encrypt шифрует файл с путём fileIn в формат DER
+// concatFiles записывает содержимое всех файлов, пути которых разрешаются относительно p.Dir,
+// в новый временный файл и возвращает путь к нему
+func concatFiles(p SSLParams, files []string) (string, error) {
+	out, err := ioutil.TempFile("", "certs")
+	if err != nil {
+		return "", err
+	}
+	for _, file := range files {
+		data, err := ioutil.ReadFile(p.resolve(file))
+		if err == nil {
+			_, err = out.Write(append(data, '\n'))
+		}
+		if err != nil {
+			out.Close()
+			os.Remove(out.Name())
+			return "", err
+		}
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(out.Name())
+		return "", err
+	}
+	return out.Name(), nil
+}
+
+// Encrypt шифрует файл с путём fileIn в формат DER для получателей, заданных в SSLParams
 // выход - зашифрованный записывается в путь fileOut
 func (s gostSSL) Encrypt(fileIn string, fileOut string) error {
+	return s.EncryptContext(context.Background(), fileIn, fileOut, encrypt.EncryptOptions{})
+}
+
+// EncryptStream шифрует данные из r, передавая их openssl через stdin,
+// результат в формате DER записывается в w
+func (s gostSSL) EncryptStream(w io.Writer, r io.Reader) error {
+	return s.EncryptStreamContext(context.Background(), w, r, encrypt.EncryptOptions{})
+}
+
+// EncryptWithOptions шифрует файл с путём fileIn в формат DER с параметрами opts,
+// выход - зашифрованный записывается в путь fileOut
+func (s gostSSL) EncryptWithOptions(fileIn string, fileOut string, opts encrypt.EncryptOptions) error {
+	return s.EncryptContext(context.Background(), fileIn, fileOut, opts)
+}
+
+// EncryptStreamWithOptions шифрует данные из r с параметрами opts, передавая их openssl через stdin,
+// результат в формате DER записывается в w
+func (s gostSSL) EncryptStreamWithOptions(w io.Writer, r io.Reader, opts encrypt.EncryptOptions) error {
+	return s.EncryptStreamContext(context.Background(), w, r, opts)
+}
+
+// EncryptContext аналогичен EncryptWithOptions, но завершает openssl при завершении ctx
+func (s gostSSL) EncryptContext(ctx context.Context, fileIn string, fileOut string, opts encrypt.EncryptOptions) error {
+	cmdParams, recipients, err := s.encryptParams(opts)
+	if err != nil {
+		return err
+	}
+	cmdParams = append(append(cmdParams, "-in", fileIn, "-out", fileOut), recipients...)
+	_, err = runOpenSSL(ctx, s.getParams(), cmdParams...)
+	return err
+}
+
+// EncryptStreamContext аналогичен EncryptStreamWithOptions, но завершает openssl при завершении ctx
+func (s gostSSL) EncryptStreamContext(ctx context.Context, w io.Writer, r io.Reader, opts encrypt.EncryptOptions) error {
+	cmdParams, recipients, err := s.encryptParams(opts)
+	if err != nil {
+		return err
+	}
+	return runOpenSSLStream(ctx, s.getParams(), r, w, append(cmdParams, recipients...)...)
+}
+
+// encryptParams возвращает аргументы openssl для шифрования данных и сертификаты получателей,
+// добавляемые после всех остальных аргументов
+func (s gostSSL) encryptParams(opts encrypt.EncryptOptions) (cmdParams []string, recipients []string, err error) {
 	// Пример командной строки:
 	// /gost-ssl/bin/openssl smime -encrypt -engine gost -gost89 -in test.xml.sgn -binary -outform der
 	// -out test.xml.sgn.enc certs/equifax1617/Боевой\ сервер/Prod_Equifax_2016-2017.cer
 	p := s.getParams()
+	cipher := opts.Cipher
+	if cipher == "" {
+		cipher = p.cipher()
+	}
+	if !validCipher(cipher) {
+		return nil, nil, ErrorUnknownCipher
+	}
+
+	recipients = opts.Recipients
+	if len(recipients) == 0 {
+		recipients = p.recipients()
+	}
+	if len(recipients) == 0 {
+		return nil, nil, ErrorNoRecipients
+	}
+	return []string{"smime", "-encrypt", "-engine", "gost", "-" + cipher, "-binary", "-outform", "der"}, recipients, nil
+}
+
+// Verify проверяет подпись файла с путём fileIn в формате DER,
+// подписанное содержимое записывается в файл с путём fileOut.
+// Ошибки openssl возвращаются как *CommandError, неверная подпись соответствует encrypt.ErrorInvalidSignature.
+// Цепочка сертификатов подписантов проверяется отдельно, её результат возвращается в VerifyResult
+func (s gostSSL) Verify(fileIn string, fileOut string) (encrypt.VerifyResult, error) {
+	return s.VerifyContext(context.Background(), fileIn, fileOut)
+}
+
+// VerifyContext аналогичен Verify, но завершает openssl при завершении ctx
+func (s gostSSL) VerifyContext(ctx context.Context, fileIn string, fileOut string) (encrypt.VerifyResult, error) {
+	// Пример командной строки:
+	// /gost-ssl/bin/openssl smime -verify -engine gost -noverify -binary -inform DER \
+	// -in test.xml.sgn -out test.xml -signer signers.pem
+	p, result := s.getParams(), encrypt.VerifyResult{}
+	signers, err := ioutil.TempFile("", "signers")
+	if err != nil {
+		return result, err
+	}
+	signers.Close()
+	defer os.Remove(signers.Name())
+
+	cmdParams := []string{
+		"smime", "-verify", "-engine", "gost", "-noverify", "-binary", "-inform", "DER",
+		"-in", fileIn,
+		"-out", fileOut,
+		"-signer", signers.Name(),
+	}
+	if _, err := runOpenSSL(ctx, p, cmdParams...); err != nil {
+		os.Remove(p.resolve(fileOut))
+		return result, err
+	}
+
+	data, err := ioutil.ReadFile(signers.Name())
+	if err != nil {
+		return result, err
+	}
+	if result.Signers, err = cert.ParseCertificates(data); err != nil {
+		return result, err
+	}
+
+	cmdParams = []string{
+		"smime", "-verify", "-engine", "gost", "-binary", "-inform", "DER",
+		"-in", fileIn,
+		"-out", os.DevNull,
+	}
+	if p.CAFilePath != "" {
+		cmdParams = append(cmdParams, "-CAfile", p.CAFilePath)
+	}
+	_, result.ChainError = runOpenSSL(ctx, p, cmdParams...)
+	if err := ctx.Err(); err != nil {
+		os.Remove(p.resolve(fileOut))
+		return result, err
+	}
+	result.ChainValid = result.ChainError == nil
+	return result, nil
+}
+
+// Decrypt расшифровывает файл с путём fileIn в формате DER нашим приватным ключом
+// выход - расшифрованный записывается в путь fileOut
+func (s gostSSL) Decrypt(fileIn string, fileOut string) error {
+	return s.DecryptContext(context.Background(), fileIn, fileOut)
+}
+
+// DecryptContext аналогичен Decrypt, но завершает openssl при завершении ctx
+func (s gostSSL) DecryptContext(ctx context.Context, fileIn string, fileOut string) error {
+	// Пример командной строки:
+	// /gost-ssl/bin/openssl smime -decrypt -engine gost -binary -inform DER -in test.xml.sgn.enc \
+	// -out test.xml.sgn -recip certs/dirname/dirname.cer -inkey private.pem
+	p := s.getParams()
 	cmdParams := []string{
-		"smime", "-encrypt", "-engine", "gost", "-gost89", "-in", fileIn, "-binary", "-outform", "der",
-		"-out", fileOut, p.ForeignCertFilePath,
+		"smime", "-decrypt", "-engine", "gost", "-binary", "-inform", "DER",
+		"-in", fileIn,
+		"-out", fileOut,
+		"-recip", p.OurCertFilePath, "-inkey", p.OurPrivateKey,
 	}
 
-	cmd := exec.Command(s.getParams().OpenSSLPath, cmdParams...)
-	// в первом параметре вывод команды
-	_, err := cmd.CombinedOutput()
-	//fmt.Println(string(output))
+	_, err := runOpenSSL(ctx, p, cmdParams...)
 	return err
 }
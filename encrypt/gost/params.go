@@ -0,0 +1,159 @@
+package gost
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// errors
+var (
+	ErrorOpenSSLNotFound = errors.New("openssl executable not found")
+	ErrorNoGostEngine    = errors.New("openssl has no gost engine")
+	ErrorFileUnreadable  = errors.New("file is not readable")
+	ErrorUnknownCipher   = errors.New("unknown cipher")
+	ErrorNoRecipients    = errors.New("no recipient certificates")
+)
+
+// DefaultCipher is a content encryption cipher used if SSLParams.Cipher is empty
+const DefaultCipher = "gost89"
+
+// SSLParams is a set of gost openssl parameters
+type SSLParams struct {
+	OpenSSLPath         string // путь к исполняемому файлу openSSL
+	OurCertFilePath     string // путь к нашему сертификату
+	ForeignCertFilePath string // путь к чужому сертификату
+	OurPrivateKey       string // путь к нашему приватному ключу
+	CAFilePath          string // путь к сертификатам доверенных УЦ, по умолчанию используются системные
+	// RecipientCertFilePaths are paths to certificates of additional recipients data is encrypted for
+	// along with ForeignCertFilePath, e.g. our own archive certificate
+	RecipientCertFilePaths []string
+	Cipher                 string // content encryption cipher name as openssl knows it, DefaultCipher if empty
+	// RefuseInvalidCerts makes constructors fail with cert.ErrorExpired or cert.ErrorNotYetValid
+	// if our or recipient certificates are not valid at the moment
+	RefuseInvalidCerts bool
+	// Timeout limits each openssl run including the gost engine check made by constructors.
+	// openssl is killed together with its children when it elapses. No limit if zero
+	Timeout time.Duration
+	Env     []string // environment variables for openssl in "KEY=value" form, e.g. "OPENSSL_CONF=/gost-ssl/openssl.cnf"
+	Dir     string   // working directory for openssl, relative file paths are resolved against it
+}
+
+// recipients returns paths to certificates of all configured recipients
+func (p SSLParams) recipients() []string {
+	recipients := []string{}
+	if p.ForeignCertFilePath != "" {
+		recipients = append(recipients, p.ForeignCertFilePath)
+	}
+	return append(recipients, p.RecipientCertFilePaths...)
+}
+
+// resolve returns path resolved against Dir if it is set and path is relative, just like openssl resolves it
+func (p SSLParams) resolve(path string) string {
+	if p.Dir == "" || path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(p.Dir, path)
+}
+
+// cipher returns configured content encryption cipher name
+func (p SSLParams) cipher() string {
+	if p.Cipher == "" {
+		return DefaultCipher
+	}
+	return p.Cipher
+}
+
+// ParamError describes an invalid SSLParams field
+type ParamError struct {
+	Field string // SSLParams field name
+	Value string // field value
+	Err   error  // the cause like ErrorOpenSSLNotFound, ErrorNoGostEngine, ErrorFileUnreadable or ErrorNoRecipients
+}
+
+// Error implements error interface
+func (e *ParamError) Error() string {
+	return fmt.Sprintf("SSLParams.%s %q: %v", e.Field, e.Value, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *ParamError) Unwrap() error { return e.Err }
+
+// paramFile is an SSLParams field holding a path to a file
+type paramFile struct {
+	field, path string
+	certificate bool // the file holds our or recipient certificate
+}
+
+// validate checks that OpenSSLPath is an executable having gost engine, Cipher is valid and all files are readable.
+// If RefuseInvalidCerts is set, certificates are checked to be valid
+func (p SSLParams) validate(files []paramFile) error {
+	if !validCipher(p.cipher()) {
+		return &ParamError{Field: "Cipher", Value: p.Cipher, Err: ErrorUnknownCipher}
+	}
+	if _, err := exec.LookPath(p.OpenSSLPath); err != nil {
+		return &ParamError{Field: "OpenSSLPath", Value: p.OpenSSLPath, Err: fmt.Errorf("%w: %v", ErrorOpenSSLNotFound, err)}
+	}
+	for _, file := range files {
+		if err := checkReadable(p.resolve(file.path)); err != nil {
+			return &ParamError{Field: file.field, Value: file.path, Err: fmt.Errorf("%w: %v", ErrorFileUnreadable, err)}
+		}
+		if file.certificate && p.RefuseInvalidCerts {
+			if err := checkValidity(p.resolve(file.path), time.Now()); err != nil {
+				return &ParamError{Field: file.field, Value: file.path, Err: err}
+			}
+		}
+	}
+
+	if _, err := runOpenSSL(context.Background(), p, "engine", "gost"); err != nil {
+		var cmdErr *CommandError
+		switch {
+		case errors.As(err, &cmdErr) && cmdErr.ExitCode >= 0:
+			return &ParamError{Field: "OpenSSLPath", Value: p.OpenSSLPath, Err: fmt.Errorf("%w: %v", ErrorNoGostEngine, err)}
+		case errors.Is(err, context.DeadlineExceeded):
+			return &ParamError{Field: "Timeout", Value: p.Timeout.String(), Err: err}
+		}
+		return &ParamError{Field: "OpenSSLPath", Value: p.OpenSSLPath, Err: fmt.Errorf("%w: %v", ErrorOpenSSLNotFound, err)}
+	}
+	return nil
+}
+
+// checkReadable returns an error if the file at path can not be read
+func checkReadable(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return errors.New("is a directory")
+	}
+	return nil
+}
+
+// cipherName matches names of openssl ciphers
+var cipherName = regexp.MustCompile(`^[a-z][a-z0-9]*(-[a-z0-9]+)*$`)
+
+// smimeOptions are openssl smime options which could be mistaken for cipher names
+var smimeOptions = map[string]bool{
+	"help": true, "in": true, "inform": true, "out": true, "outform": true, "inkey": true, "keyform": true,
+	"engine": true, "stream": true, "indef": true, "noindef": true, "config": true, "encrypt": true,
+	"decrypt": true, "sign": true, "resign": true, "verify": true, "passin": true, "md": true,
+	"nointern": true, "nodetach": true, "noattr": true, "binary": true, "signer": true, "content": true,
+	"nocerts": true, "nosigs": true, "noverify": true, "certfile": true, "recip": true, "to": true,
+	"from": true, "subject": true, "text": true, "nosmimecap": true, "nochain": true, "crlfeol": true,
+	"rand": true, "writerand": true, "policy": true, "purpose": true, "attime": true, "provider": true,
+	"propquery": true,
+}
+
+// validCipher returns true if name looks like an openssl cipher name and is not an smime option
+func validCipher(name string) bool { return cipherName.MatchString(name) && !smimeOptions[name] }
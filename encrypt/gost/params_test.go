@@ -0,0 +1,110 @@
+package gost
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/mtfelian/utils/encrypt/cert"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SSLParams validation", func() {
+	var params SSLParams
+
+	BeforeEach(func() {
+		createTestFile()
+		params = getSSLParams()
+		params.OpenSSLPath = writeFakeOpenSSL("", 0)
+	})
+	AfterEach(func() { cleanup() })
+
+	It("accepts valid params", func() {
+		signerEncryptor, err := NewGostSignerEncryptor(params)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(signerEncryptor).NotTo(BeNil())
+		verifierDecryptor, err := NewGostVerifierDecryptor(params)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(verifierDecryptor).NotTo(BeNil())
+	})
+
+	It("checks for invalid params", func() {
+		testCases := []struct {
+			name   string
+			modify func(p *SSLParams)
+			field  string
+			err    error
+		}{
+			{"no openssl", func(p *SSLParams) { p.OpenSSLPath = filepath.Join(testPath, "missing") },
+				"OpenSSLPath", ErrorOpenSSLNotFound},
+			{"no gost engine", func(p *SSLParams) { p.OpenSSLPath = writeFakeOpenSSL("", 1) },
+				"OpenSSLPath", ErrorNoGostEngine},
+			{"no certificate", func(p *SSLParams) { p.OurCertFilePath = "" },
+				"OurCertFilePath", ErrorFileUnreadable},
+			{"key is a directory", func(p *SSLParams) { p.OurPrivateKey = testPath },
+				"OurPrivateKey", ErrorFileUnreadable},
+			{"no foreign certificate", func(p *SSLParams) { p.ForeignCertFilePath = filepath.Join(testPath, "missing") },
+				"ForeignCertFilePath", ErrorFileUnreadable},
+			{"no recipient certificate", func(p *SSLParams) {
+				p.RecipientCertFilePaths = []string{p.OurCertFilePath, filepath.Join(testPath, "missing")}
+			}, "RecipientCertFilePaths", ErrorFileUnreadable},
+			{"invalid cipher", func(p *SSLParams) { p.Cipher = "-out" }, "Cipher", ErrorUnknownCipher},
+			{"engine check timeout", func(p *SSLParams) {
+				p.OpenSSLPath, p.Timeout = writeScript("openssl-hung", "sleep 30"), 200*time.Millisecond
+			}, "Timeout", context.DeadlineExceeded},
+			{"no recipients", func(p *SSLParams) { p.ForeignCertFilePath, p.RecipientCertFilePaths = "", nil },
+				"ForeignCertFilePath", ErrorNoRecipients},
+		}
+		for _, tc := range testCases {
+			By(fmt.Sprintf("testing case %s", tc.name))
+			p := params
+			tc.modify(&p)
+			_, err := NewGostSignerEncryptor(p)
+			Expect(errors.Is(err, tc.err)).To(BeTrue())
+			var paramErr *ParamError
+			Expect(errors.As(err, &paramErr)).To(BeTrue())
+			Expect(paramErr.Field).To(Equal(tc.field))
+		}
+	})
+
+	It("checks ForeignCertFilePath only if it is set", func() {
+		params.ForeignCertFilePath = ""
+		params.RecipientCertFilePaths = []string{params.OurCertFilePath}
+		signerEncryptor, err := NewGostSignerEncryptor(params)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(signerEncryptor.(*gostSSL).params.recipients()).To(Equal([]string{params.OurCertFilePath}))
+	})
+
+	It("resolves relative file paths against Dir", func() {
+		params.Dir = testPath
+		params.OurCertFilePath, params.ForeignCertFilePath, params.OurPrivateKey = "cert.cer", "cert.cer", "private.pem"
+		_, err := NewGostSignerEncryptor(params)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = NewGostVerifierDecryptor(params)
+		Expect(err).NotTo(HaveOccurred())
+		certs, err := params.Certificates()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(certs).To(HaveLen(2))
+
+		params.RefuseInvalidCerts = true
+		_, err = NewGostSignerEncryptor(params)
+		Expect(errors.Is(err, cert.ErrorExpired)).To(BeTrue())
+
+		params.RefuseInvalidCerts, params.Dir = false, ""
+		_, err = NewGostSignerEncryptor(params)
+		Expect(errors.Is(err, ErrorFileUnreadable)).To(BeTrue())
+	})
+
+	It("checks CAFilePath only if it is set", func() {
+		params.ForeignCertFilePath = ""
+		_, err := NewGostVerifierDecryptor(params)
+		Expect(err).NotTo(HaveOccurred())
+
+		params.CAFilePath = filepath.Join(testPath, "missing")
+		_, err = NewGostVerifierDecryptor(params)
+		Expect(errors.Is(err, ErrorFileUnreadable)).To(BeTrue())
+	})
+})
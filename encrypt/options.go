@@ -0,0 +1,62 @@
+package encrypt
+
+import (
+	"errors"
+	"io"
+)
+
+// errors
+var (
+	ErrorUnknownFormat = errors.New("unknown signature format")
+)
+
+// Format is an output format of a signature
+type Format int
+
+// signature formats
+const (
+	FormatDER   Format = iota // binary CMS
+	FormatPEM                 // base64 encoded CMS with PEM armor
+	FormatSMIME               // S/MIME message
+)
+
+// String implements fmt.Stringer
+func (f Format) String() string {
+	switch f {
+	case FormatDER:
+		return "DER"
+	case FormatPEM:
+		return "PEM"
+	case FormatSMIME:
+		return "SMIME"
+	}
+	return "unknown"
+}
+
+// SignOptions is a set of signing options.
+// Zero value means an attached DER signature without signed attributes, just like SignDER makes
+type SignOptions struct {
+	Detached   bool     // write the signature only, without the signed data
+	Format     Format   // output format
+	Attributes bool     // include signed attributes such as signing time
+	ExtraCerts []string // paths to PEM files with additional certificates to embed into the signature
+}
+
+// OptionsSigner умеет подписать файл или поток с заданными параметрами подписи
+type OptionsSigner interface {
+	Sign(fileIn string, fileOut string, opts SignOptions) error
+	SignStream(w io.Writer, r io.Reader, opts SignOptions) error
+}
+
+// EncryptOptions is a set of encryption options. Zero value means encryption
+// for recipients configured for the implementation with its default cipher
+type EncryptOptions struct {
+	Recipients []string // paths to certificates of recipients, replacing the configured ones if not empty
+	Cipher     string   // content encryption cipher name, implementation default if empty
+}
+
+// OptionsEncryptor умеет зашифровать файл или поток с заданными параметрами шифрования
+type OptionsEncryptor interface {
+	EncryptWithOptions(fileIn string, fileOut string, opts EncryptOptions) error
+	EncryptStreamWithOptions(w io.Writer, r io.Reader, opts EncryptOptions) error
+}
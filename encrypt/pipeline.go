@@ -0,0 +1,134 @@
+package encrypt
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/mtfelian/utils/compress"
+)
+
+// errors
+var (
+	ErrorSingleInput         = errors.New("exactly one input file is needed without a compressor")
+	ErrorNoSignerEncryptor   = errors.New("pipeline has no SignerEncryptor")
+	ErrorNoVerifierDecryptor = errors.New("pipeline has no VerifierDecryptor")
+)
+
+// Pipeline signs and encrypts files, optionally compressing them first, and runs the reverse path.
+// Intermediate files are kept in a temporary directory removed when processing ends
+type Pipeline struct {
+	SignerEncryptor   SignerEncryptor       // used by SignEncrypt
+	VerifierDecryptor VerifierDecryptor     // used by DecryptVerify
+	Compressor        compress.Compressor   // compresses input of SignEncrypt, may be nil
+	Decompressor      compress.Decompressor // decompresses output of DecryptVerify, may be nil
+	TempDir           string                // directory to create temporary directories in, os.TempDir() if empty
+}
+
+// SignEncrypt signs and encrypts input into fileOut. If Compressor is set, files and directories
+// from pathIn are compressed into a single archive first, otherwise pathIn should be a single file.
+// ErrorNoSignerEncryptor is returned if SignerEncryptor is nil, compress.ErrorNoInput is returned
+// if pathIn is empty. On error fileOut is removed
+func (p Pipeline) SignEncrypt(fileOut string, pathIn ...string) error {
+	return p.SignEncryptContext(context.Background(), fileOut, pathIn...)
+}
+
+// SignEncryptContext is like SignEncrypt but stops when ctx is done. Compressor, SignerEncryptor
+// not implementing compress.ContextCompressor and ContextSignerEncryptor are only stopped between steps
+func (p Pipeline) SignEncryptContext(ctx context.Context, fileOut string, pathIn ...string) (err error) {
+	if p.SignerEncryptor == nil {
+		return ErrorNoSignerEncryptor
+	}
+	if len(pathIn) == 0 {
+		return compress.ErrorNoInput
+	}
+	if p.Compressor == nil && len(pathIn) != 1 {
+		return ErrorSingleInput
+	}
+	tempDir, err := ioutil.TempDir(p.TempDir, "pipeline")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tempDir)
+	defer func() {
+		if err != nil {
+			os.Remove(fileOut)
+		}
+	}()
+
+	fileIn := pathIn[0]
+	if p.Compressor != nil {
+		fileIn = filepath.Join(tempDir, "data")
+		if err := compressContext(ctx, p.Compressor, fileIn, pathIn...); err != nil {
+			return err
+		}
+	}
+	signed := filepath.Join(tempDir, "signed")
+	if err := signContext(ctx, p.SignerEncryptor, fileIn, signed); err != nil {
+		return err
+	}
+	return encryptContext(ctx, p.SignerEncryptor, signed, fileOut)
+}
+
+// DecryptVerify decrypts fileIn and verifies its signature. If Decompressor is set, the signed data
+// is decompressed into directory pathOut, otherwise it is written into file pathOut.
+// Signer certificates chain validity is reported in the result and is not treated as an error.
+// ErrorNoVerifierDecryptor is returned if VerifierDecryptor is nil.
+// On error file pathOut is removed, though files already decompressed into pathOut are left
+func (p Pipeline) DecryptVerify(fileIn string, pathOut string) (VerifyResult, error) {
+	return p.DecryptVerifyContext(context.Background(), fileIn, pathOut)
+}
+
+// DecryptVerifyContext is like DecryptVerify but stops when ctx is done. Decompressor, VerifierDecryptor
+// not implementing compress.ContextDecompressor and ContextVerifierDecryptor are only stopped between steps
+func (p Pipeline) DecryptVerifyContext(ctx context.Context, fileIn string, pathOut string) (result VerifyResult, err error) {
+	if p.VerifierDecryptor == nil {
+		return result, ErrorNoVerifierDecryptor
+	}
+	tempDir, err := ioutil.TempDir(p.TempDir, "pipeline")
+	if err != nil {
+		return result, err
+	}
+	defer os.RemoveAll(tempDir)
+
+	decrypted := filepath.Join(tempDir, "decrypted")
+	if err := decryptContext(ctx, p.VerifierDecryptor, fileIn, decrypted); err != nil {
+		return result, err
+	}
+	if p.Decompressor == nil {
+		if result, err = verifyContext(ctx, p.VerifierDecryptor, decrypted, pathOut); err != nil {
+			os.Remove(pathOut)
+		}
+		return result, err
+	}
+
+	data := filepath.Join(tempDir, "data")
+	if result, err = verifyContext(ctx, p.VerifierDecryptor, decrypted, data); err != nil {
+		return result, err
+	}
+	return result, decompressContext(ctx, p.Decompressor, data, pathOut)
+}
+
+// compressContext compresses pathIn into fileOut with c using compress.ContextCompressor if c implements it
+func compressContext(ctx context.Context, c compress.Compressor, fileOut string, pathIn ...string) error {
+	if cc, ok := c.(compress.ContextCompressor); ok {
+		return cc.CompressContext(ctx, fileOut, pathIn...)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.Compress(fileOut, pathIn...)
+}
+
+// decompressContext decompresses fileIn into pathOut with d using compress.ContextDecompressor if d implements it
+func decompressContext(ctx context.Context, d compress.Decompressor, fileIn string, pathOut string) error {
+	if cd, ok := d.(compress.ContextDecompressor); ok {
+		return cd.DecompressContext(ctx, fileIn, pathOut)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return d.Decompress(fileIn, pathOut)
+}
@@ -0,0 +1,167 @@
+package encrypt
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/mtfelian/utils/compress"
+	"github.com/mtfelian/utils/compress/zip"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// fakeVerifierDecryptor reverses fileSignerEncryptor transformations
+type fakeVerifierDecryptor struct{ err error }
+
+func (d *fakeVerifierDecryptor) Verify(fileIn string, fileOut string) (VerifyResult, error) {
+	if err := d.strip(fileIn, fileOut, "signed:"); err != nil {
+		return VerifyResult{}, ErrorInvalidSignature
+	}
+	return VerifyResult{ChainValid: true}, nil
+}
+
+func (d *fakeVerifierDecryptor) Decrypt(fileIn string, fileOut string) error {
+	if d.err != nil {
+		return d.err
+	}
+	return d.strip(fileIn, fileOut, "encrypted:")
+}
+
+func (d *fakeVerifierDecryptor) strip(fileIn string, fileOut string, prefix string) error {
+	data, err := ioutil.ReadFile(fileIn)
+	if err != nil {
+		return err
+	}
+	if !bytes.HasPrefix(data, []byte(prefix)) {
+		return errors.New("no prefix")
+	}
+	return ioutil.WriteFile(fileOut, bytes.TrimPrefix(data, []byte(prefix)), 0600)
+}
+
+var _ = Describe("Pipeline", func() {
+	var tempPath, pipelineTemp, srcPath string
+
+	BeforeEach(func() {
+		var err error
+		tempPath, err = ioutil.TempDir("", "encrypt")
+		Expect(err).NotTo(HaveOccurred())
+		pipelineTemp, srcPath = filepath.Join(tempPath, "temp"), filepath.Join(tempPath, "src")
+		Expect(os.MkdirAll(pipelineTemp, 0777)).To(Succeed())
+		Expect(os.MkdirAll(filepath.Join(srcPath, "dir"), 0777)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(srcPath, "a"), []byte("a data"), 0660)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(srcPath, "dir", "b"), []byte("b data"), 0660)).To(Succeed())
+	})
+	AfterEach(func() { Expect(os.RemoveAll(tempPath)).To(Succeed()) })
+
+	expectNoTempFiles := func() {
+		files, err := ioutil.ReadDir(pipelineTemp)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(files).To(BeEmpty())
+	}
+
+	It("signs and encrypts a single file and back", func() {
+		p := Pipeline{
+			SignerEncryptor:   &fileSignerEncryptor{},
+			VerifierDecryptor: &fakeVerifierDecryptor{},
+			TempDir:           pipelineTemp,
+		}
+		fileOut := filepath.Join(tempPath, "a.enc")
+		Expect(p.SignEncrypt(fileOut, filepath.Join(srcPath, "a"))).To(Succeed())
+		Expect(ioutil.ReadFile(fileOut)).To(Equal([]byte("encrypted:signed:a data")))
+		expectNoTempFiles()
+
+		decrypted := filepath.Join(tempPath, "a.dec")
+		result, err := p.DecryptVerify(fileOut, decrypted)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.ChainValid).To(BeTrue())
+		Expect(ioutil.ReadFile(decrypted)).To(Equal([]byte("a data")))
+		expectNoTempFiles()
+
+		Expect(p.SignEncrypt(fileOut, srcPath, srcPath)).To(MatchError(ErrorSingleInput))
+	})
+
+	It("compresses before signing and decompresses after verifying", func() {
+		z := zip.New(zip.Params{KeepDirs: true})
+		p := Pipeline{
+			SignerEncryptor:   &fileSignerEncryptor{},
+			VerifierDecryptor: &fakeVerifierDecryptor{},
+			Compressor:        z,
+			Decompressor:      z,
+			TempDir:           pipelineTemp,
+		}
+		fileOut := filepath.Join(tempPath, "src.zip.enc")
+		Expect(p.SignEncrypt(fileOut, srcPath)).To(Succeed())
+		expectNoTempFiles()
+
+		pathOut := filepath.Join(tempPath, "out")
+		_, err := p.DecryptVerify(fileOut, pathOut)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ioutil.ReadFile(filepath.Join(pathOut, "src", "a"))).To(Equal([]byte("a data")))
+		Expect(ioutil.ReadFile(filepath.Join(pathOut, "src", "dir", "b"))).To(Equal([]byte("b data")))
+		expectNoTempFiles()
+
+		Expect(p.SignEncrypt(fileOut)).To(MatchError(compress.ErrorNoInput))
+		p.Compressor = nil
+		Expect(p.SignEncrypt(fileOut)).To(MatchError(compress.ErrorNoInput))
+	})
+
+	It("removes output and temporary files on failure", func() {
+		p := Pipeline{
+			SignerEncryptor:   &fileSignerEncryptor{},
+			VerifierDecryptor: &fakeVerifierDecryptor{},
+			TempDir:           pipelineTemp,
+		}
+		empty := filepath.Join(srcPath, "empty")
+		Expect(ioutil.WriteFile(empty, nil, 0660)).To(Succeed())
+		fileOut := filepath.Join(tempPath, "empty.enc")
+		Expect(p.SignEncrypt(fileOut, empty)).To(MatchError("no data"))
+		Expect(fileOut).NotTo(BeAnExistingFile())
+		expectNoTempFiles()
+
+		// signature is missing
+		Expect(p.SignerEncryptor.Encrypt(filepath.Join(srcPath, "a"), fileOut)).To(Succeed())
+		decrypted := filepath.Join(tempPath, "a.dec")
+		_, err := p.DecryptVerify(fileOut, decrypted)
+		Expect(err).To(MatchError(ErrorInvalidSignature))
+		Expect(decrypted).NotTo(BeAnExistingFile())
+		expectNoTempFiles()
+
+		p.VerifierDecryptor = &fakeVerifierDecryptor{err: errors.New("wrong key")}
+		_, err = p.DecryptVerify(fileOut, decrypted)
+		Expect(err).To(MatchError("wrong key"))
+		expectNoTempFiles()
+	})
+
+	It("fails without SignerEncryptor or VerifierDecryptor", func() {
+		fileOut := filepath.Join(tempPath, "a.enc")
+		Expect(Pipeline{}.SignEncrypt(fileOut, filepath.Join(srcPath, "a"))).To(MatchError(ErrorNoSignerEncryptor))
+		Expect(fileOut).NotTo(BeAnExistingFile())
+		_, err := Pipeline{}.DecryptVerify(fileOut, filepath.Join(tempPath, "a.dec"))
+		Expect(err).To(MatchError(ErrorNoVerifierDecryptor))
+	})
+
+	It("stops on context cancellation", func() {
+		p := Pipeline{
+			SignerEncryptor:   &fileSignerEncryptor{},
+			VerifierDecryptor: &fakeVerifierDecryptor{},
+			Compressor:        zip.New(zip.Params{}),
+			TempDir:           pipelineTemp,
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		fileOut := filepath.Join(tempPath, "a.enc")
+		Expect(errors.Is(p.SignEncryptContext(ctx, fileOut, srcPath), context.Canceled)).To(BeTrue())
+		Expect(fileOut).NotTo(BeAnExistingFile())
+		expectNoTempFiles()
+
+		p.Compressor = nil
+		Expect(p.SignEncrypt(fileOut, filepath.Join(srcPath, "a"))).To(Succeed())
+		_, err := p.DecryptVerifyContext(ctx, fileOut, filepath.Join(tempPath, "a.dec"))
+		Expect(err).To(MatchError(context.Canceled))
+		expectNoTempFiles()
+	})
+})
@@ -0,0 +1,79 @@
+package encrypt
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// SignBytes signs data with s and returns it together with the signature in DER format.
+// If s is not a StreamSigner, data is passed to it through temporary files
+func SignBytes(s Signer, data []byte) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	if err := SignStream(s, buf, bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// EncryptBytes encrypts data with e and returns the result in DER format.
+// If e is not a StreamEncryptor, data is passed to it through temporary files
+func EncryptBytes(e Encryptor, data []byte) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	if err := EncryptStream(e, buf, bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// SignStream signs data read from r with s and writes it together with the signature in DER format into w.
+// If s is not a StreamSigner, data is passed to it through temporary files
+func SignStream(s Signer, w io.Writer, r io.Reader) error {
+	if streamSigner, ok := s.(StreamSigner); ok {
+		return streamSigner.SignDERStream(w, r)
+	}
+	return throughFiles(s.SignDER, w, r)
+}
+
+// EncryptStream encrypts data read from r with e and writes the result in DER format into w.
+// If e is not a StreamEncryptor, data is passed to it through temporary files
+func EncryptStream(e Encryptor, w io.Writer, r io.Reader) error {
+	if streamEncryptor, ok := e.(StreamEncryptor); ok {
+		return streamEncryptor.EncryptStream(w, r)
+	}
+	return throughFiles(e.Encrypt, w, r)
+}
+
+// throughFiles writes r into a temporary file, calls fn on it and copies the output file into w
+func throughFiles(fn func(fileIn string, fileOut string) error, w io.Writer, r io.Reader) error {
+	dir, err := ioutil.TempDir("", "encrypt")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	in, err := ioutil.TempFile(dir, "in")
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(in, r); err != nil {
+		in.Close()
+		return err
+	}
+	if err := in.Close(); err != nil {
+		return err
+	}
+
+	fileOut := in.Name() + ".out"
+	if err := fn(in.Name(), fileOut); err != nil {
+		return err
+	}
+	out, err := os.Open(fileOut)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	_, err = io.Copy(w, out)
+	return err
+}
@@ -0,0 +1,80 @@
+package encrypt
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// fileSignerEncryptor is a SignerEncryptor working with files only
+type fileSignerEncryptor struct{ files []string }
+
+func (s *fileSignerEncryptor) SignDER(fileIn string, fileOut string) error {
+	return s.transform(fileIn, fileOut, "signed:")
+}
+
+func (s *fileSignerEncryptor) Encrypt(fileIn string, fileOut string) error {
+	return s.transform(fileIn, fileOut, "encrypted:")
+}
+
+func (s *fileSignerEncryptor) transform(fileIn string, fileOut string, prefix string) error {
+	s.files = append(s.files, fileIn, fileOut)
+	data, err := ioutil.ReadFile(fileIn)
+	if err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		return errors.New("no data")
+	}
+	return ioutil.WriteFile(fileOut, append([]byte(prefix), data...), 0600)
+}
+
+// streamSignerEncryptor is a SignerEncryptor also implementing StreamSignerEncryptor
+type streamSignerEncryptor struct{ fileSignerEncryptor }
+
+func (s *streamSignerEncryptor) SignDERStream(w io.Writer, r io.Reader) error {
+	io.WriteString(w, "stream signed:")
+	_, err := io.Copy(w, r)
+	return err
+}
+
+func (s *streamSignerEncryptor) EncryptStream(w io.Writer, r io.Reader) error {
+	io.WriteString(w, "stream encrypted:")
+	_, err := io.Copy(w, r)
+	return err
+}
+
+var _ = Describe("Testing with Ginkgo", func() {
+	It("checks SignBytes/EncryptBytes through files", func() {
+		s := &fileSignerEncryptor{}
+		Expect(SignBytes(s, []byte("data"))).To(Equal([]byte("signed:data")))
+		Expect(EncryptBytes(s, []byte("data"))).To(Equal([]byte("encrypted:data")))
+
+		_, err := SignBytes(s, nil)
+		Expect(err).To(MatchError("no data"))
+
+		Expect(s.files).To(HaveLen(6))
+		for _, f := range s.files {
+			By("testing case " + f)
+			Expect(f).NotTo(BeAnExistingFile())
+			Expect(filepath.Dir(f)).NotTo(BeADirectory())
+		}
+		Expect(filepath.Dir(s.files[0])).NotTo(Equal(os.TempDir()))
+	})
+
+	It("checks SignStream/EncryptStream prefer stream implementations", func() {
+		s := &streamSignerEncryptor{}
+		buf := &bytes.Buffer{}
+		Expect(SignStream(s, buf, strings.NewReader("data"))).To(Succeed())
+		Expect(buf.String()).To(Equal("stream signed:data"))
+		Expect(EncryptBytes(s, []byte("data"))).To(Equal([]byte("stream encrypted:data")))
+		Expect(s.files).To(BeEmpty())
+	})
+})
@@ -0,0 +1,97 @@
+// Package command runs external tools bounded by a context and a timeout
+package command
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// Runner runs external commands
+type Runner struct {
+	// Timeout limits each run, the process group is killed when it elapses. No limit if zero
+	Timeout time.Duration
+	// Env holds environment variables in "KEY=value" form, e.g. "OPENSSL_CONF=/etc/gost.cnf",
+	// added to the environment of the current process
+	Env []string
+	Dir string // working directory, the current one if empty
+}
+
+// Run runs executable at path with args, feeding it stdin and writing its output into stdout and stderr,
+// any of which may be nil. If ctx is done or Timeout elapses before the command exits, its whole
+// process group is killed and ctx error is returned, even if reading stdin blocks. The goroutine copying
+// stdin is left to exit when the read returns then. Otherwise the error of exec.Cmd Start or Wait
+// or of reading stdin is returned
+func (r Runner) Run(ctx context.Context, path string, stdin io.Reader, stdout, stderr io.Writer, args ...string) error {
+	if r.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.Timeout)
+		defer cancel()
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	cmd := exec.Command(path, args...)
+	cmd.Stdout, cmd.Stderr, cmd.Dir = stdout, stderr, r.Dir
+	if len(r.Env) > 0 {
+		cmd.Env = append(os.Environ(), r.Env...)
+	}
+	// exec.Cmd Wait waits for its own stdin copying which may block forever on a stalled reader,
+	// so stdin is copied here and the pipe is closed by Wait regardless of the copying state
+	var stdinPipe io.WriteCloser
+	if stdin != nil {
+		var err error
+		if stdinPipe, err = cmd.StdinPipe(); err != nil {
+			return err
+		}
+	}
+	setProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	var stdinErr chan error
+	if stdin != nil {
+		stdinErr = make(chan error, 1)
+		go func() {
+			_, err := io.Copy(stdinPipe, stdin)
+			stdinPipe.Close()
+			stdinErr <- err
+		}()
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			killProcessGroup(cmd.Process)
+		case <-done:
+		}
+	}()
+
+	if err := cmd.Wait(); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return err
+	}
+	if stdinErr == nil {
+		return nil
+	}
+	select {
+	case err := <-stdinErr:
+		// the command may exit or close its stdin without reading it all
+		if err != nil && !errors.Is(err, syscall.EPIPE) && !errors.Is(err, os.ErrClosed) {
+			return err
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
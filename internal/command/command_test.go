@@ -0,0 +1,90 @@
+package command
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing/iotest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Testing with Ginkgo", func() {
+	var tempPath string
+
+	BeforeEach(func() {
+		var err error
+		tempPath, err = ioutil.TempDir("", "command")
+		Expect(err).NotTo(HaveOccurred())
+	})
+	AfterEach(func() { Expect(os.RemoveAll(tempPath)).To(Succeed()) })
+
+	It("checks Env, Dir and stdin", func() {
+		stdout := &bytes.Buffer{}
+		r := Runner{Env: []string{"OPENSSL_CONF=/etc/gost.cnf"}, Dir: tempPath}
+		err := r.Run(context.Background(), "/bin/sh", strings.NewReader("input"), stdout, nil,
+			"-c", `echo "$OPENSSL_CONF"; pwd; cat`)
+		Expect(err).NotTo(HaveOccurred())
+		dir, err := filepath.EvalSymlinks(tempPath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stdout.String()).To(Equal("/etc/gost.cnf\n" + dir + "\ninput"))
+	})
+
+	It("checks failed commands", func() {
+		err := Runner{}.Run(context.Background(), "/bin/sh", nil, nil, nil, "-c", "exit 3")
+		Expect(err).To(BeAssignableToTypeOf(&exec.ExitError{}))
+		Expect(err.(*exec.ExitError).ExitCode()).To(Equal(3))
+
+		err = Runner{}.Run(context.Background(), filepath.Join(tempPath, "missing"), nil, nil, nil)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("kills the process group on timeout", func() {
+		// the child inherits stdout, so Run would wait for it if it survived
+		stdout := &bytes.Buffer{}
+		start := time.Now()
+		err := Runner{Timeout: 200 * time.Millisecond}.Run(context.Background(), "/bin/sh", nil, stdout, nil,
+			"-c", "sleep 30 & echo started; wait")
+		Expect(err).To(Equal(context.DeadlineExceeded))
+		Expect(time.Since(start)).To(BeNumerically("<", 10*time.Second))
+		Expect(stdout.String()).To(Equal("started\n"))
+	})
+
+	It("stops on timeout when stdin stalls", func() {
+		stdin, stdinWriter := io.Pipe()
+		defer stdinWriter.Close()
+		start := time.Now()
+		err := Runner{Timeout: 200 * time.Millisecond}.Run(context.Background(), "/bin/sh", stdin, nil, nil,
+			"-c", "sleep 30")
+		Expect(err).To(Equal(context.DeadlineExceeded))
+		Expect(time.Since(start)).To(BeNumerically("<", 10*time.Second))
+	})
+
+	It("checks stdin errors", func() {
+		stdin := io.MultiReader(strings.NewReader("input"), iotest.ErrReader(errors.New("read failed")))
+		err := Runner{}.Run(context.Background(), "/bin/sh", stdin, nil, nil, "-c", "cat >/dev/null")
+		Expect(err).To(MatchError("read failed"))
+
+		// the command does not read stdin
+		stdin = io.MultiReader(strings.NewReader("input"), strings.NewReader(strings.Repeat("x", 1<<20)))
+		Expect(Runner{}.Run(context.Background(), "/bin/sh", stdin, nil, nil, "-c", "true")).To(Succeed())
+	})
+
+	It("stops on context cancellation", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		Expect(Runner{}.Run(ctx, "/bin/sh", nil, nil, nil, "-c", "true")).To(Equal(context.Canceled))
+
+		ctx, cancel = context.WithCancel(context.Background())
+		time.AfterFunc(100*time.Millisecond, cancel)
+		Expect(Runner{}.Run(ctx, "/bin/sh", nil, nil, nil, "-c", "sleep 30")).To(Equal(context.Canceled))
+	})
+})
@@ -0,0 +1,15 @@
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris
+// +build !aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package command
+
+import (
+	"os"
+	"os/exec"
+)
+
+// setProcessGroup does nothing where process groups are not supported
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup kills process p, its children are left running where process groups are not supported
+func killProcessGroup(p *os.Process) { p.Kill() }
@@ -10,6 +10,7 @@ import (
 	"math"
 	"math/rand"
 	"mime/multipart"
+	"net"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -439,14 +440,12 @@ func PBool(b bool) *bool {
 	return &b
 }
 
-// GetIPAddress пытается получить IP адрес из заголовков HTTP
-// возвращает соотв-ю строку, или "0.0.0.0"
+// GetIPAddress пытается получить IPv4 или IPv6 адрес из заголовков HTTP
+// возвращает соотв-ю строку, или "0.0.0.0".
+//
+// Deprecated: headers are trusted no matter who sent them, so clients can spoof their address.
+// Use IPResolver instead
 func GetIPAddress(request *http.Request) string {
-	regexpIP4 := regexp.MustCompile(`^(25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)\.` +
-		`(25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)\.` +
-		`(25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)\.` +
-		`(25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)$`)
-
 	ipKeys := []string{
 		"X-Real-Ip",
 		"HTTP_CLIENT_IP",
@@ -463,19 +462,216 @@ func GetIPAddress(request *http.Request) string {
 		if headerValue != "" {
 			headerValueParts := strings.Split(headerValue, ",")
 			for _, headerValuePart := range headerValueParts {
-				ip := strings.TrimSpace(headerValuePart)
-				if regexpIP4.MatchString(ip) {
-					return ip
+				if ip := parseIPAddress(strings.TrimSpace(headerValuePart)); ip != nil {
+					return ip.String()
 				}
 			}
 		}
 	}
+	return "0.0.0.0"
+}
+
+// IPResolverParams is a set of IPResolver parameters
+type IPResolverParams struct {
+	// TrustedProxies are CIDRs like "10.0.0.0/8" or single addresses of proxies trusted to append
+	// client addresses to X-Forwarded-For and Forwarded headers
+	TrustedProxies []string
+}
+
+// IPResolver resolves client IP addresses of HTTP requests passed through trusted proxies
+type IPResolver struct {
+	trusted []*net.IPNet
+}
+
+// IPSource is a source a client IP address was taken from
+type IPSource string
+
+// client IP address sources
+const (
+	IPSourceRemoteAddr    IPSource = "RemoteAddr"      // http.Request RemoteAddr field
+	IPSourceForwarded     IPSource = "Forwarded"       // RFC 7239 Forwarded header
+	IPSourceXForwardedFor IPSource = "X-Forwarded-For" // X-Forwarded-For header
+)
+
+// IPScope is an address scope of an IP address
+type IPScope int
 
-	remoteAddr := request.Header.Get("REMOTE_ADDR")
-	if remoteAddr != "" {
-		return remoteAddr
+// IP address scopes
+const (
+	IPScopePublic      IPScope = iota // globally routable address
+	IPScopePrivate                    // RFC 1918 IPv4 or RFC 4193 IPv6 unique local address
+	IPScopeLoopback                   // 127.0.0.0/8 or ::1
+	IPScopeLinkLocal                  // 169.254.0.0/16 or fe80::/10
+	IPScopeUnspecified                // 0.0.0.0 or ::
+)
+
+// String implements fmt.Stringer
+func (s IPScope) String() string {
+	switch s {
+	case IPScopePrivate:
+		return "private"
+	case IPScopeLoopback:
+		return "loopback"
+	case IPScopeLinkLocal:
+		return "link-local"
+	case IPScopeUnspecified:
+		return "unspecified"
+	}
+	return "public"
+}
+
+// GetIPScope returns the address scope of ip
+func GetIPScope(ip net.IP) IPScope {
+	switch {
+	case ip.IsUnspecified():
+		return IPScopeUnspecified
+	case ip.IsLoopback():
+		return IPScopeLoopback
+	case ip.IsLinkLocalUnicast():
+		return IPScopeLinkLocal
+	case ip.IsPrivate():
+		return IPScopePrivate
+	}
+	return IPScopePublic
+}
+
+// ClientIP is a resolved client IP address
+type ClientIP struct {
+	IP     net.IP   // IPv4 addresses, including IPv4-mapped IPv6 ones, are 4 bytes long
+	Source IPSource // where IP was taken from
+	Scope  IPScope  // address scope of IP
+}
+
+// NewIPResolver returns a new IPResolver with given params. An error is returned if a trusted proxy is invalid
+func NewIPResolver(params IPResolverParams) (*IPResolver, error) {
+	r := &IPResolver{}
+	for _, proxy := range params.TrustedProxies {
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", proxy)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			r.trusted = append(r.trusted, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", proxy, err)
+		}
+		r.trusted = append(r.trusted, network)
+	}
+	return r, nil
+}
+
+// Resolve returns the client IP address of request. If request.RemoteAddr is not a trusted proxy,
+// it is the client address. Otherwise addresses from Forwarded headers, or from X-Forwarded-For headers
+// if there are no Forwarded ones, are walked from the right skipping trusted proxies, and the first
+// untrusted address is returned. If an address in the chain is malformed or hidden, the last trusted
+// proxy before it is returned, and if all addresses are trusted, the leftmost one is returned.
+// Nil is returned if request.RemoteAddr is malformed
+func (r *IPResolver) Resolve(request *http.Request) net.IP { return r.ResolveClient(request).IP }
+
+// ResolveClient resolves the client IP address of request just like Resolve does
+// and reports where the address was taken from and its scope.
+// IP is nil if request.RemoteAddr is malformed
+func (r *IPResolver) ResolveClient(request *http.Request) ClientIP {
+	ip := parseIPAddress(request.RemoteAddr)
+	if ip == nil {
+		return ClientIP{Source: IPSourceRemoteAddr}
+	}
+	client := ClientIP{IP: ip, Source: IPSourceRemoteAddr, Scope: GetIPScope(ip)}
+	if !r.isTrusted(ip) {
+		return client
+	}
+	chain, source := forwardedFor(request.Header)
+	for i := len(chain) - 1; i >= 0; i-- {
+		forwarded := parseIPAddress(chain[i])
+		if forwarded == nil {
+			return client
+		}
+		client = ClientIP{IP: forwarded, Source: source, Scope: GetIPScope(forwarded)}
+		if !r.isTrusted(forwarded) {
+			return client
+		}
 	}
-	return "0.0.0.0"
+	return client
+}
+
+// isTrusted returns true if ip is an address of a trusted proxy
+func (r *IPResolver) isTrusted(ip net.IP) bool {
+	for _, network := range r.trusted {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardedFor returns client addresses from RFC 7239 Forwarded headers "for" parameters,
+// or from X-Forwarded-For headers if there are no Forwarded ones, from left to right, and their source
+func forwardedFor(header http.Header) ([]string, IPSource) {
+	result := []string{}
+	if values := header["Forwarded"]; len(values) > 0 {
+		for _, value := range values {
+			for _, element := range splitQuoted(value, ',') {
+				addr := ""
+				for _, pair := range splitQuoted(element, ';') {
+					if i := strings.Index(pair, "="); i >= 0 && strings.EqualFold(strings.TrimSpace(pair[:i]), "for") {
+						addr = strings.Trim(strings.TrimSpace(pair[i+1:]), `"`)
+					}
+				}
+				result = append(result, addr)
+			}
+		}
+		return result, IPSourceForwarded
+	}
+	for _, value := range header["X-Forwarded-For"] {
+		for _, addr := range strings.Split(value, ",") {
+			result = append(result, strings.TrimSpace(addr))
+		}
+	}
+	return result, IPSourceXForwardedFor
+}
+
+// splitQuoted splits s by sep outside of double quoted strings
+func splitQuoted(s string, sep byte) []string {
+	result, quoted, start := []string{}, false, 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '"':
+			quoted = !quoted
+		case s[i] == '\\' && quoted:
+			i++
+		case s[i] == sep && !quoted:
+			result = append(result, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(result, s[start:])
+}
+
+// parseIPAddress parses an IPv4 or IPv6 address optionally followed by a port, like "192.0.2.1:80",
+// "2001:db8::1", "[2001:db8::1]" or "[2001:db8::1]:80". IPv6 zones like "%eth0" are dropped,
+// IPv4 and IPv4-mapped IPv6 addresses are returned 4 bytes long
+func parseIPAddress(s string) net.IP {
+	host := s
+	if strings.HasPrefix(s, "[") && strings.HasSuffix(s, "]") {
+		host = s[1 : len(s)-1]
+	} else if h, _, err := net.SplitHostPort(s); err == nil {
+		host = h
+	}
+	if i := strings.LastIndex(host, "%"); i >= 0 && strings.Contains(host, ":") {
+		host = host[:i]
+	}
+	ip := net.ParseIP(host)
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4
+	}
+	return ip
 }
 
 // CallerFuncName возвращает имя функции, вызвавшей функцию, из которой была вызвана CallerFuncName()
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"io/ioutil"
 	"math"
+	"net"
+	"net/http"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -743,3 +745,92 @@ var _ = Describe("MarshalUnmarshalJSON func", func() {
 		}
 	})
 })
+
+var _ = Describe("IPResolver", func() {
+	It("checks IPResolver", func() {
+		resolver, err := NewIPResolver(IPResolverParams{TrustedProxies: []string{"10.0.0.0/8", "192.0.2.1"}})
+		Expect(err).NotTo(HaveOccurred())
+
+		type testCase struct {
+			remoteAddr string
+			header     http.Header
+			expected   string
+		}
+		testCases := []testCase{
+			{"203.0.113.5:1234", http.Header{"X-Forwarded-For": {"1.1.1.1"}, "X-Real-Ip": {"1.1.1.1"}}, "203.0.113.5"},
+			{"10.0.0.1:80", nil, "10.0.0.1"},
+			{"10.0.0.1:80", http.Header{"X-Forwarded-For": {"1.1.1.1, 198.51.100.7, 10.0.0.2"}}, "198.51.100.7"},
+			{"10.0.0.1:80", http.Header{"X-Forwarded-For": {"1.1.1.1", "198.51.100.7, 192.0.2.1"}}, "198.51.100.7"},
+			{"10.0.0.1:80", http.Header{"X-Forwarded-For": {"10.1.1.1, 10.0.0.2"}}, "10.1.1.1"},
+			{"10.0.0.1:80", http.Header{"X-Forwarded-For": {"198.51.100.7, garbage, 10.0.0.2"}}, "10.0.0.2"},
+			{"10.0.0.1:80", http.Header{
+				"Forwarded":       {`for=1.1.1.1, for="198.51.100.7:4711";proto=https`, "For=10.0.0.2;by=10.0.0.1"},
+				"X-Forwarded-For": {"8.8.8.8"},
+			}, "198.51.100.7"},
+			{"10.0.0.1:80", http.Header{"Forwarded": {`for=_hidden, for=10.0.0.2`}}, "10.0.0.2"},
+			{"192.0.2.1:443", http.Header{"Forwarded": {`proto=http;for="198.51.100.7"`}}, "198.51.100.7"},
+			{"192.0.2.2:443", http.Header{"Forwarded": {`for=198.51.100.7`}}, "192.0.2.2"},
+		}
+		for i, tc := range testCases {
+			By(fmt.Sprintf("testing case %d", i))
+			ip := resolver.Resolve(&http.Request{RemoteAddr: tc.remoteAddr, Header: tc.header})
+			Expect(ip.String()).To(Equal(tc.expected))
+		}
+		Expect(resolver.Resolve(&http.Request{RemoteAddr: "garbage"})).To(BeNil())
+
+		for _, proxy := range []string{"10.0.0.0/33", "proxy.local"} {
+			By(fmt.Sprintf("testing invalid proxy %s", proxy))
+			_, err := NewIPResolver(IPResolverParams{TrustedProxies: []string{proxy}})
+			Expect(err).To(HaveOccurred())
+		}
+	})
+
+	It("checks IPv6 addresses in IPResolver", func() {
+		resolver, err := NewIPResolver(IPResolverParams{TrustedProxies: []string{"fd00::/8", "10.0.0.0/8", "::1"}})
+		Expect(err).NotTo(HaveOccurred())
+
+		type testCase struct {
+			remoteAddr string
+			header     http.Header
+			expected   ClientIP
+		}
+		testCases := []testCase{
+			{"[2001:db8::1]:443", nil, ClientIP{IP: net.ParseIP("2001:db8::1"), Source: IPSourceRemoteAddr, Scope: IPScopePublic}},
+			{"[::1]:80", nil, ClientIP{IP: net.ParseIP("::1"), Source: IPSourceRemoteAddr, Scope: IPScopeLoopback}},
+			{"[fd00::2]:80", http.Header{"Forwarded": {`for="[2001:db8:cafe::17]:4711", for="[fd00::3]"`}},
+				ClientIP{IP: net.ParseIP("2001:db8:cafe::17"), Source: IPSourceForwarded, Scope: IPScopePublic}},
+			{"[::ffff:10.0.0.1]:80", http.Header{"X-Forwarded-For": {"::ffff:192.168.1.10, fe80::1%eth0"}},
+				ClientIP{IP: net.ParseIP("fe80::1"), Source: IPSourceXForwardedFor, Scope: IPScopeLinkLocal}},
+			{"10.0.0.1:80", http.Header{"X-Forwarded-For": {"::ffff:192.168.1.10, [fd12::1]:8080"}},
+				ClientIP{IP: net.IPv4(192, 168, 1, 10).To4(), Source: IPSourceXForwardedFor, Scope: IPScopePrivate}},
+			{"10.0.0.1:80", http.Header{"X-Forwarded-For": {"[::]"}},
+				ClientIP{IP: net.IPv6unspecified, Source: IPSourceXForwardedFor, Scope: IPScopeUnspecified}},
+		}
+		for i, tc := range testCases {
+			By(fmt.Sprintf("testing case %d", i))
+			client := resolver.ResolveClient(&http.Request{RemoteAddr: tc.remoteAddr, Header: tc.header})
+			Expect(client.IP.Equal(tc.expected.IP)).To(BeTrue())
+			Expect(client.Source).To(Equal(tc.expected.Source))
+			Expect(client.Scope).To(Equal(tc.expected.Scope))
+		}
+		Expect(resolver.ResolveClient(&http.Request{RemoteAddr: "10.0.0.1:80"}).IP).To(HaveLen(net.IPv4len))
+		Expect(resolver.ResolveClient(&http.Request{RemoteAddr: "[]:80"})).To(Equal(ClientIP{Source: IPSourceRemoteAddr}))
+		Expect(IPScopeLinkLocal.String()).To(Equal("link-local"))
+	})
+})
+
+var _ = Describe("GetIPAddress func", func() {
+	It("checks GetIPAddress", func() {
+		testCases := map[string]http.Header{
+			"203.0.113.5":  {"X-Real-Ip": {"203.0.113.5"}},
+			"2001:db8::1":  {"X-Real-Ip": {"2001:db8::1"}},
+			"192.168.1.10": {"Http_x_forwarded_for": {"unknown, ::ffff:192.168.1.10"}},
+			"2001:db8::2":  {"Remote_addr": {"[2001:db8::2]:1234"}},
+			"0.0.0.0":      {"Remote_addr": {"garbage"}},
+		}
+		for expected, header := range testCases {
+			By(fmt.Sprintf("testing case %s", expected))
+			Expect(GetIPAddress(&http.Request{Header: header})).To(Equal(expected))
+		}
+	})
+})